@@ -0,0 +1,82 @@
+package script
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Builtin creates a pipe that implements a command in-process. It gets the arguments of the
+// command, without the command name.
+type Builtin func(args []string) Pipe
+
+// builtins holds the registered builtins.
+var builtins = struct {
+	sync.RWMutex
+	m map[string]Builtin
+	// only forbids running commands that have no builtin.
+	only bool
+}{m: map[string]Builtin{}}
+
+// RegisterBuiltin registers an in-process implementation of a command. When a stream executes a
+// command with `Exec`, or any of its variants, and a builtin was registered with the command name,
+// the pipe of the builtin is used instead of running a process. Registering a nil builtin removes
+// the registration.
+//
+// Options of the execution that concern the process, such as the working directory or the
+// environment, don't apply to builtins.
+//
+// For example, the following makes `Exec("grep", "-v", <re>)` filter lines in-process:
+//
+//	script.RegisterBuiltin("grep", func(args []string) script.Pipe {
+//		return script.ModifierPipe(script.Grep{Re: regexp.MustCompile(args[1]), Inverse: true})
+//	})
+func RegisterBuiltin(name string, builtin Builtin) {
+	builtins.Lock()
+	defer builtins.Unlock()
+	if builtin == nil {
+		delete(builtins.m, name)
+		return
+	}
+	builtins.m[name] = builtin
+}
+
+// BuiltinsOnly sets whether commands are only executed by registered builtins. When set, executing
+// a command that has no registered builtin fails instead of running a process. This makes streams
+// hermetic.
+func BuiltinsOnly(only bool) {
+	builtins.Lock()
+	defer builtins.Unlock()
+	builtins.only = only
+}
+
+// lookupBuiltin returns the builtin of a command. It returns an error if the command has no
+// builtin and only builtins are allowed.
+func lookupBuiltin(name string) (Builtin, error) {
+	builtins.RLock()
+	defer builtins.RUnlock()
+	if builtin, ok := builtins.m[name]; ok {
+		return builtin, nil
+	}
+	if builtins.only {
+		return nil, fmt.Errorf("command %s has no registered builtin", name)
+	}
+	return nil, nil
+}
+
+// pipeBuiltin runs the command of e with its builtin.
+func (e exe) pipeBuiltin(builtin Builtin, stdin io.Reader) (io.Reader, error) {
+	// Builtins don't write to stderr.
+	if e.stderrOut != nil {
+		e.stderrOut.CloseWrite()
+	}
+	if e.opts.Stdin != nil {
+		stdin = e.opts.Stdin
+	}
+	if stdin == nil {
+		stdin = strings.NewReader("")
+	}
+
+	return pipeContext(e.ctx, builtin(e.args), stdin)
+}
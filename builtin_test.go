@@ -0,0 +1,91 @@
+package script
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// upper is a builtin that converts its input to upper case, and appends its arguments to each line.
+func upper(args []string) Pipe {
+	suffix := []byte(strings.Join(args, ""))
+	return ModifierPipe(ModifyFn(func(line []byte) ([]byte, error) {
+		if line == nil {
+			return nil, nil
+		}
+		return append(bytes.ToUpper(append(line, suffix...)), '\n'), nil
+	}))
+}
+
+// Not parallel, since builtins are global.
+func TestBuiltin(t *testing.T) {
+	RegisterBuiltin("upper", upper)
+	defer RegisterBuiltin("upper", nil)
+
+	t.Run("stream", func(t *testing.T) {
+		got, err := Echo("a\nb").Exec("upper", "c").ToString()
+		require.NoError(t, err)
+		assert.Equal(t, "AC\nBC\n", got)
+	})
+
+	t.Run("source", func(t *testing.T) {
+		got, err := Exec("upper").ToString()
+		require.NoError(t, err)
+		assert.Equal(t, "", got)
+	})
+
+	t.Run("stdin option", func(t *testing.T) {
+		got, err := ExecWith(ExecOptions{Stdin: bytes.NewBufferString("x")}, "upper").ToString()
+		require.NoError(t, err)
+		assert.Equal(t, "X\n", got)
+	})
+
+	t.Run("context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := Echo("a").WithContext(ctx).Exec("upper").ToString()
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("split", func(t *testing.T) {
+		stdout, stderr := Echo("a").ExecSplit("upper")
+		got, err := stdout.ToString()
+		require.NoError(t, err)
+		assert.Equal(t, "A\n", got)
+		got, err = stderr.ToString()
+		require.NoError(t, err)
+		assert.Equal(t, "", got)
+	})
+
+	t.Run("parse", func(t *testing.T) {
+		s, err := Parse("echo a | upper")
+		require.NoError(t, err)
+		got, err := s.ToString()
+		require.NoError(t, err)
+		assert.Equal(t, "A\n", got)
+	})
+
+	t.Run("unregistered", func(t *testing.T) {
+		RegisterBuiltin("upper", nil)
+		defer RegisterBuiltin("upper", upper)
+
+		_, err := Exec("upper").ToString()
+		assert.Error(t, err)
+	})
+
+	t.Run("builtins only", func(t *testing.T) {
+		BuiltinsOnly(true)
+		defer BuiltinsOnly(false)
+
+		got, err := Echo("a").Exec("upper").ToString()
+		require.NoError(t, err)
+		assert.Equal(t, "A\n", got)
+
+		_, err = Exec("echo", "a").ToString()
+		assert.EqualError(t, err, "stage 0 (exec(echo, [a])): command echo has no registered builtin")
+	})
+}
@@ -0,0 +1,397 @@
+package script
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// activeCassette is the cassette that is used for executing commands, or nil.
+var activeCassette atomic.Pointer[Cassette]
+
+// Cassette records the executions of commands to a file, and replays them later without running
+// the commands. It lets tests of streams that execute external tools run deterministically, and
+// without the tools installed.
+//
+// A cassette is used by all the streams that execute commands with `Exec`, or any of its variants,
+// until it is closed. Registered builtins take precedence over the cassette.
+type Cassette struct {
+	path      string
+	recording bool
+
+	mu    sync.Mutex
+	execs []*recordedExec
+	// used marks recorded executions that were already replayed.
+	used []bool
+}
+
+// recordedExec is a single execution of a command in a cassette file.
+type recordedExec struct {
+	Cmd  string   `json:"cmd"`
+	Args []string `json:"args"`
+	// Stdin is the SHA-256 digest of the stdin that the command read.
+	Stdin string `json:"stdin"`
+	// StdinTruncated is set when the command exited before it read all its stdin. Then, StdinSize
+	// is the number of bytes it read.
+	StdinTruncated bool   `json:"stdin_truncated,omitempty"`
+	StdinSize      int64  `json:"stdin_size,omitempty"`
+	Stdout         string `json:"stdout"`
+	Stderr         string `json:"stderr"`
+	ExitCode       int    `json:"exit_code"`
+	// Err is the error message of a failed command.
+	Err string `json:"err,omitempty"`
+}
+
+type cassetteFile struct {
+	Executions []*recordedExec `json:"executions"`
+}
+
+// UseCassette starts using a cassette at the given path, usually under the `testdata` directory.
+// If the file does not exist, the commands are executed and recorded, and the file is written when
+// the cassette is closed. Otherwise, the recorded executions are replayed instead of running the
+// commands. To record the cassette again, remove the file.
+//
+// An execution is replayed for a command with the same name, arguments and stdin. Other options of
+// the command, such as its working directory and environment, are not matched. Each recorded
+// execution is replayed once, in the order they were recorded. Executing a command that has no
+// recorded execution fails the stage.
+//
+// Only the part of the stdin that a command read when it was recorded is matched. For example, for
+// `Exec("yes").Exec("head", "-n", "1")`, the replayed `head` reads as much of the output of `yes` as
+// the recorded one did. The stdin is read when the output of the command is first read.
+//
+// Only one cassette can be used at a time.
+//
+//	c, err := script.UseCassette("testdata/deploy.json")
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer c.Close()
+func UseCassette(path string) (*Cassette, error) {
+	c := &Cassette{path: path}
+	b, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		c.recording = true
+	case err != nil:
+		return nil, fmt.Errorf("read cassette: %w", err)
+	default:
+		var f cassetteFile
+		if err := json.Unmarshal(b, &f); err != nil {
+			return nil, fmt.Errorf("parse cassette %s: %w", path, err)
+		}
+		c.execs = f.Executions
+		c.used = make([]bool, len(f.Executions))
+	}
+	if !activeCassette.CompareAndSwap(nil, c) {
+		return nil, errors.New("a cassette is already in use")
+	}
+	return c, nil
+}
+
+// Recording returns whether the cassette records executions, or replays them.
+func (c *Cassette) Recording() bool { return c.recording }
+
+// Close stops using the cassette. A recording cassette writes the recorded executions to its file.
+func (c *Cassette) Close() error {
+	activeCassette.CompareAndSwap(c, nil)
+	if !c.recording {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, err := json.MarshalIndent(cassetteFile{Executions: c.execs}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode cassette: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("create cassette directory: %w", err)
+	}
+	if err := os.WriteFile(c.path, append(b, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write cassette: %w", err)
+	}
+	return nil
+}
+
+// pipe records or replays the execution of the command of e.
+func (c *Cassette) pipe(e exe, stdin io.Reader) (io.Reader, error) {
+	if e.opts.Stdin != nil {
+		stdin = e.opts.Stdin
+	}
+	if c.recording {
+		return c.record(e, stdin)
+	}
+	return c.replay(e, stdin)
+}
+
+// record runs the command of e and records its execution once its stage is closed.
+func (c *Cassette) record(e exe, stdin io.Reader) (io.Reader, error) {
+	rec := &recordedExec{Cmd: e.cmd, Args: e.args}
+
+	in := &hashReader{h: sha256.New()}
+	if stdin != nil {
+		in.r = stdin
+		e.opts.Stdin = in
+	}
+	var stderr bytes.Buffer
+	if e.opts.Stderr != nil {
+		e.opts.Stderr = io.MultiWriter(e.opts.Stderr, &stderr)
+	} else {
+		e.opts.Stderr = &stderr
+	}
+	var cmd *exec.Cmd
+	hook := e.opts.Hook
+	e.opts.Hook = func(c *exec.Cmd) {
+		cmd = c
+		if hook != nil {
+			hook(c)
+		}
+	}
+
+	r, err := e.run(nil)
+	if err != nil {
+		return r, err
+	}
+	var stdout bytes.Buffer
+	return readcloser{
+		Reader: io.TeeReader(r, &stdout),
+		Closer: closerFn(func() error {
+			err := r.(io.Closer).Close()
+			if cmd.ProcessState == nil {
+				return err
+			}
+			var execErr *ExecError
+			if err != nil && !errors.As(err, &execErr) {
+				return err
+			}
+			// The stdin is not read to its end, since it may be unbounded.
+			rec.Stdin = hex.EncodeToString(in.h.Sum(nil))
+			if stdin != nil && !in.eof {
+				rec.StdinTruncated = true
+				rec.StdinSize = in.n
+			}
+			rec.Stdout = stdout.String()
+			rec.Stderr = stderr.String()
+			if execErr != nil {
+				rec.ExitCode = execErr.ExitCode
+				rec.Err = execErr.Err.Error()
+			} else if code := cmd.ProcessState.ExitCode(); e.okExitCode(code) {
+				rec.ExitCode = code
+			}
+			c.mu.Lock()
+			c.execs = append(c.execs, rec)
+			c.mu.Unlock()
+			return err
+		}),
+	}, nil
+}
+
+// replay returns a reader of a recorded execution of the command of e. The execution is matched on
+// the first read.
+func (c *Cassette) replay(e exe, stdin io.Reader) (io.Reader, error) {
+	var r io.Reader = &replayReader{c: c, e: e, stdin: stdin}
+	if e.ctx != nil {
+		r = ctxReader{ctx: e.ctx, r: r}
+	}
+	return r, nil
+}
+
+// replayReader reads the outputs of a recorded execution.
+type replayReader struct {
+	c     *Cassette
+	e     exe
+	stdin io.Reader
+	// started is set once the execution was matched, rec is the matched execution and err is the
+	// error of matching it.
+	started bool
+	rec     *recordedExec
+	out     io.Reader
+	err     error
+}
+
+func (r *replayReader) Read(b []byte) (int, error) {
+	if !r.started {
+		r.start()
+	}
+	// A failure to replay is reported by `Close`.
+	if r.err != nil {
+		return 0, io.EOF
+	}
+	return r.out.Read(b)
+}
+
+// start matches the execution and writes its stderr.
+func (r *replayReader) start() {
+	r.started = true
+	defer r.closeStderr()
+
+	r.rec, r.err = r.c.take(r.e.cmd, r.e.args, &stdinMatcher{r: r.stdin})
+	if r.err != nil {
+		return
+	}
+	if r.rec == nil {
+		r.err = fmt.Errorf("cassette %s has no recorded execution of %s", r.c.path, r.e.Name())
+		return
+	}
+	if r.e.opts.Stderr != nil {
+		io.WriteString(r.e.opts.Stderr, r.rec.Stderr)
+	}
+	if r.e.stderrOut != nil {
+		io.WriteString(r.e.stderrOut, r.rec.Stderr)
+	}
+	r.out = strings.NewReader(r.rec.Stdout)
+}
+
+func (r *replayReader) closeStderr() {
+	if r.e.stderrOut != nil {
+		r.e.stderrOut.CloseWrite()
+	}
+}
+
+func (r *replayReader) Close() error {
+	if !r.started {
+		// The output was not read, and the stderr will not be written.
+		r.started = true
+		r.closeStderr()
+		return nil
+	}
+	if r.err != nil {
+		return r.err
+	}
+	if r.rec.Err == "" {
+		return nil
+	}
+	return &ExecError{
+		Cmd:      r.e.cmd,
+		Args:     r.e.args,
+		ExitCode: r.rec.ExitCode,
+		Stderr:   lastBytes([]byte(r.rec.Stderr), execStderrTail),
+		Err:      errors.New(r.rec.Err),
+	}
+}
+
+// take returns the first recorded execution that matches a command, and was not replayed yet.
+//
+// The lock is not held while the stdin is read, since reading it may replay the previous command.
+func (c *Cassette) take(cmd string, args []string, stdin *stdinMatcher) (*recordedExec, error) {
+	for i := 0; ; i++ {
+		c.mu.Lock()
+		for i < len(c.execs) && (c.used[i] || !c.execs[i].matchCmd(cmd, args)) {
+			i++
+		}
+		if i == len(c.execs) {
+			c.mu.Unlock()
+			return nil, nil
+		}
+		rec := c.execs[i]
+		c.mu.Unlock()
+
+		ok, err := stdin.match(rec)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		c.mu.Lock()
+		taken := c.used[i]
+		c.used[i] = true
+		c.mu.Unlock()
+		if !taken {
+			return rec, nil
+		}
+	}
+}
+
+// matchCmd returns whether the execution is of the given command.
+func (rec *recordedExec) matchCmd(cmd string, args []string) bool {
+	if rec.Cmd != cmd {
+		return false
+	}
+	// Treat nil and empty arguments the same.
+	return (len(rec.Args) == 0 && len(args) == 0) || reflect.DeepEqual(rec.Args, args)
+}
+
+// stdinMatcher matches the stdin of a command to recorded executions. It reads only as much of the
+// stdin as needed.
+type stdinMatcher struct {
+	r io.Reader
+	// buf holds the stdin that was read.
+	buf []byte
+	eof bool
+}
+
+func (m *stdinMatcher) match(rec *recordedExec) (bool, error) {
+	size := int64(-1)
+	if rec.StdinTruncated {
+		size = rec.StdinSize
+	}
+	if err := m.fill(size); err != nil {
+		return false, err
+	}
+	in := m.buf
+	if rec.StdinTruncated {
+		if int64(len(in)) < size {
+			return false, nil
+		}
+		in = in[:size]
+	}
+	digest := sha256.Sum256(in)
+	return hex.EncodeToString(digest[:]) == rec.Stdin, nil
+}
+
+// fill reads the stdin until n bytes were read, or to its end if n is negative.
+func (m *stdinMatcher) fill(n int64) error {
+	if m.r == nil {
+		m.eof = true
+	}
+	for !m.eof && (n < 0 || int64(len(m.buf)) < n) {
+		chunk := make([]byte, 32<<10)
+		k, err := m.r.Read(chunk)
+		m.buf = append(m.buf, chunk[:k]...)
+		if err == io.EOF {
+			m.eof = true
+		} else if err != nil {
+			return fmt.Errorf("read stdin: %w", err)
+		}
+	}
+	return nil
+}
+
+// hashReader hashes the data that is read from it, and counts it.
+type hashReader struct {
+	r   io.Reader
+	h   hash.Hash
+	n   int64
+	eof bool
+}
+
+func (r *hashReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.h.Write(b[:n])
+	r.n += int64(n)
+	if err == io.EOF {
+		r.eof = true
+	}
+	return n, err
+}
+
+func lastBytes(b []byte, n int) []byte {
+	if len(b) > n {
+		return b[len(b)-n:]
+	}
+	return b
+}
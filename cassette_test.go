@@ -0,0 +1,107 @@
+package script
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCassette(t *testing.T) {
+	// Not parallel, the cassette applies to all the streams.
+	path := filepath.Join(t.TempDir(), "testdata", "cassette.json")
+
+	// run executes streams and returns their outputs. The first command outputs a different value
+	// on every execution.
+	run := func(t *testing.T) (outputs []string, stderr string) {
+		out, err := Exec("sh", "-c", "date +%s%N").ToString()
+		require.NoError(t, err)
+		outputs = append(outputs, out)
+
+		out, err = Echo("a\nb").Exec("cat").ToString()
+		require.NoError(t, err)
+		outputs = append(outputs, out)
+
+		var buf bytes.Buffer
+		out, err = ExecHandleStderr(&buf, "sh", "-c", "echo out; echo oops >&2; exit 3").ToString()
+		outputs = append(outputs, out)
+		stderr = buf.String()
+
+		var execErr *ExecError
+		require.True(t, errors.As(err, &execErr))
+		assert.Equal(t, 3, execErr.ExitCode)
+		assert.EqualError(t, execErr, "exit status 3: oops")
+		return outputs, stderr
+	}
+
+	c, err := UseCassette(path)
+	require.NoError(t, err)
+	assert.True(t, c.Recording())
+
+	_, err = UseCassette(path)
+	assert.Error(t, err)
+
+	recorded, recordedStderr := run(t)
+	require.NoError(t, c.Close())
+	// The outputs are stored as text.
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"stdout": "a\nb\n"`)
+	assert.Contains(t, string(b), `"stderr": "oops\n"`)
+
+	c, err = UseCassette(path)
+	require.NoError(t, err)
+	defer c.Close()
+	assert.False(t, c.Recording())
+
+	replayed, replayedStderr := run(t)
+	assert.Equal(t, recorded, replayed)
+	assert.Equal(t, "oops\n", recordedStderr)
+	assert.Equal(t, recordedStderr, replayedStderr)
+
+	t.Run("replayed once", func(t *testing.T) {
+		_, err := Echo("a\nb").Exec("cat").ToString()
+		assert.EqualError(t, err, "stage 1 (exec(cat, [])): cassette "+path+" has no recorded execution of exec(cat, [])")
+	})
+
+	t.Run("different stdin", func(t *testing.T) {
+		_, err := Echo("c").Exec("cat").ToString()
+		assert.Error(t, err)
+	})
+}
+
+func TestCassette_unboundedStdin(t *testing.T) {
+	// Not parallel, the cassette applies to all the streams.
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	for _, recording := range []bool{true, false} {
+		c, err := UseCassette(path)
+		require.NoError(t, err)
+		assert.Equal(t, recording, c.Recording())
+
+		out, err := Exec("yes").Exec("head", "-n", "1").ToString()
+		require.NoError(t, err)
+		assert.Equal(t, "y\n", out)
+		require.NoError(t, c.Close())
+	}
+}
+
+func TestCassette_lazyReplay(t *testing.T) {
+	// Not parallel, the cassette applies to all the streams.
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"executions": []}`), 0o644))
+	c, err := UseCassette(path)
+	require.NoError(t, err)
+	defer c.Close()
+
+	// Building the stream does not read the stdin, which is never written.
+	r, w := io.Pipe()
+	defer w.Close()
+	s := From("pipe", r).Exec("cat")
+	assert.NoError(t, s.Close())
+}
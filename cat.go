@@ -1,6 +1,7 @@
 package script
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -15,23 +16,41 @@ func Cat(paths ...string) Stream {
 		readers []io.Reader
 		closers multicloser
 		merr    error
+		// size is the total size of the files, or -1 if it is unknown.
+		size int64
 	)
 
 	for _, path := range paths {
 		f, err := os.Open(path)
 		if err != nil {
 			merr = errors.Join(merr, fmt.Errorf("open path %s: %w", path, err))
+			continue
+		}
+		readers = append(readers, f)
+		closers = append(closers, f)
+		if info, err := f.Stat(); err == nil && info.Mode().IsRegular() && size >= 0 {
+			size += info.Size()
 		} else {
-			readers = append(readers, f)
-			closers = append(closers, f)
+			size = -1
 		}
 	}
+	if size < 0 {
+		size = 0
+	}
 
 	return Stream{
 		r:     readcloser{Reader: io.MultiReader(readers...), Closer: closers},
 		stage: "cat",
+		shell: quoteAll(append([]string{"cat"}, paths...)),
 		err:   merr,
-	}
+		size:  size,
+	}.measure(map[string]string{"paths": quoteAll(paths)})
+}
+
+// CatContext outputs the contents of the given files. Reading the stream stops once the given
+// context is done. The returned stream is bound to the context.
+func CatContext(ctx context.Context, paths ...string) Stream {
+	return Cat(paths...).WithContext(ctx)
 }
 
 type multicloser []io.Closer
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/posener/script"
+)
+
+var (
+	assignRe   = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)=(.*)$`)
+	variableRe = regexp.MustCompile(`\$(?:([A-Za-z_][A-Za-z0-9_]*)|\{([A-Za-z_][A-Za-z0-9_]*)\})`)
+)
+
+// interpreter executes lines of a pipeline file.
+type interpreter struct {
+	vars map[string]string
+	// errexit stops the execution on the first failure, like `set -e`.
+	errexit bool
+
+	stdout, stderr io.Writer
+}
+
+func newInterpreter(stdout, stderr io.Writer) *interpreter {
+	return &interpreter{
+		vars:    map[string]string{},
+		errexit: true,
+		stdout:  stdout,
+		stderr:  stderr,
+	}
+}
+
+// run executes all the lines of a file. Failures are reported to the stderr, and the last failure
+// is returned.
+func (in *interpreter) run(name string, r io.Reader) error {
+	var lastErr error
+	scanner := bufio.NewScanner(r)
+	for i := 1; scanner.Scan(); i++ {
+		line := scanner.Text()
+		if err := in.exec(line); err != nil {
+			in.report(fmt.Sprintf("%s:%d", name, i), line, err)
+			lastErr = err
+			if in.errexit {
+				return err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(in.stderr, "script: reading %s: %v\n", name, err)
+		return err
+	}
+	return lastErr
+}
+
+// exec executes a single line.
+func (in *interpreter) exec(line string) error {
+	line = strings.TrimSpace(line)
+	switch {
+	case line == "" || strings.HasPrefix(line, "#"):
+		return nil
+	case line == "set -e":
+		in.errexit = true
+		return nil
+	case line == "set +e":
+		in.errexit = false
+		return nil
+	}
+
+	if m := assignRe.FindStringSubmatch(line); m != nil {
+		value, err := unquote(m[2])
+		if err != nil {
+			return err
+		}
+		in.vars[m[1]] = value
+		return nil
+	}
+
+	s, err := script.Parse(in.expand(line))
+	if err != nil {
+		return err
+	}
+	return s.To(in.stdout)
+}
+
+// expand substitutes variables in a line with their value, like a shell does. Outside of quotes, the
+// value is quoted as a single word. Inside double quotes, the value is escaped, and inside single
+// quotes, variables are not substituted.
+func (in *interpreter) expand(line string) string {
+	var (
+		out strings.Builder
+		// quote is the quote that the current position is in, or 0.
+		quote byte
+	)
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\' && quote != '\'' && i+1 < len(line):
+			out.WriteString(line[i : i+2])
+			i++
+			continue
+		case c == '\'' && quote != '"', c == '"' && quote != '\'':
+			if quote == 0 {
+				quote = c
+			} else {
+				quote = 0
+			}
+		case c == '$' && quote != '\'':
+			if m := variableRe.FindStringSubmatch(line[i:]); m != nil && strings.HasPrefix(line[i:], m[0]) {
+				value := in.lookup(m[1] + m[2])
+				if quote == '"' {
+					out.WriteString(escapeDoubleQuoted(value))
+				} else {
+					out.WriteString(script.Quote(value))
+				}
+				i += len(m[0]) - 1
+				continue
+			}
+		}
+		out.WriteByte(c)
+	}
+	return out.String()
+}
+
+// lookup returns the value of a variable, which is taken from the environment if it was not
+// assigned.
+func (in *interpreter) lookup(name string) string {
+	if value, ok := in.vars[name]; ok {
+		return value
+	}
+	return os.Getenv(name)
+}
+
+// escapeDoubleQuoted escapes a value to be inserted in double quotes.
+func escapeDoubleQuoted(value string) string {
+	var out strings.Builder
+	for i := 0; i < len(value); i++ {
+		if strings.IndexByte("\"\\$`", value[i]) >= 0 {
+			out.WriteByte('\\')
+		}
+		out.WriteByte(value[i])
+	}
+	return out.String()
+}
+
+// report writes a failure of a line to the stderr. Each failing stage of a pipeline is written in
+// a separate line.
+func (in *interpreter) report(pos, line string, err error) {
+	fmt.Fprintf(in.stderr, "script: %s: %s\n", pos, strings.TrimSpace(line))
+	var errs []error
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	} else {
+		errs = []error{err}
+	}
+	for _, err := range errs {
+		indent := "\t"
+		var stageErr *script.StageError
+		if errors.As(err, &stageErr) {
+			fmt.Fprintf(in.stderr, "\tstage %d: %s\n", stageErr.Index, stageErr.Stage)
+			err = stageErr.Err
+			indent = "\t\t"
+		}
+		for _, msg := range strings.Split(err.Error(), "\n") {
+			fmt.Fprintf(in.stderr, "%s%s\n", indent, msg)
+		}
+	}
+}
+
+// unquote returns the value of a variable assignment, which may be quoted by single or double
+// quotes.
+func unquote(value string) (string, error) {
+	if len(value) == 0 || (value[0] != '\'' && value[0] != '"') {
+		return value, nil
+	}
+	if len(value) < 2 || value[len(value)-1] != value[0] {
+		return "", fmt.Errorf("unterminated quote in %s", value)
+	}
+	return value[1 : len(value)-1], nil
+}
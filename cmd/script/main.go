@@ -0,0 +1,77 @@
+// Command script executes pipeline files, using the built-in commands of the
+// github.com/posener/script package.
+//
+// Usage:
+//
+//	script FILE
+//	script repl
+//
+// Each line of the file is a pipeline, such as `cat a.txt | grep -v foo | sort -r > out.txt`, that is
+// parsed with `script.Parse`: commands that are implemented by the package, such as `cat`, `grep`,
+// `sort`, `head`, `tail`, `cut`, `uniq` and `wc`, don't depend on the commands that are installed
+// on the machine, and other commands are executed. The output of each pipeline is written to the
+// stdout.
+//
+// Additionally, a file may contain:
+//
+// * Empty lines and comments that start with `#`.
+//
+// * Variables assignments in the form of `NAME=value`. Variables are referenced in pipelines as
+// `$NAME` or `${NAME}`, and are substituted as a single word, or as part of a word inside double
+// quotes. Variables are not substituted inside single quotes. Referenced variables that were not
+// assigned are taken from the environment.
+//
+// * `set -e`, which stops the execution on the first failing pipeline, and `set +e`, which
+// continues the execution after failures. `set -e` is the default.
+//
+// When a pipeline fails, the failing stages are printed to the stderr, and the exit code of the
+// command is 1.
+//
+// # REPL
+//
+// `script repl` builds a pipeline interactively. Each entered line is added as a stage to the
+// pipeline, and the first lines of the output of the pipeline are previewed. The last stage can be
+// removed with `:undo`, and `:go` prints Go code of the pipeline. Enter `:help` for all the
+// commands. Since the whole pipeline runs again for every added stage, a pipeline that executes
+// commands that are not implemented by the package, or writes to a file, runs only once it is
+// confirmed.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command with the given arguments and returns its exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) != 1 {
+		fmt.Fprintln(stderr, "usage: script FILE | script repl")
+		return 2
+	}
+
+	if args[0] == "repl" {
+		if err := newREPL(stdout, stderr).run(stdin); err != nil {
+			fmt.Fprintf(stderr, "script: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		fmt.Fprintf(stderr, "script: %v\n", err)
+		return 1
+	}
+	defer f.Close()
+
+	in := newInterpreter(stdout, stderr)
+	if err := in.run(args[0], f); err != nil {
+		return 1
+	}
+	return 0
+}
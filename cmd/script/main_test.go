@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out.txt")
+
+	tests := []struct {
+		name       string
+		file       string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			name: "pipelines",
+			file: `# A comment.
+FILE=../../testdata/b.txt
+cat ../../testdata/a.txt $FILE | sort -r
+
+echo "${FILE}" | cut -d / -f 4
+`,
+			wantStdout: "bb\na\nb.txt\n",
+		},
+		{
+			name:       "variable with spaces",
+			file:       "A='a  b'\necho $A | grep b\n",
+			wantStdout: "a  b\n",
+		},
+		{
+			name:       "variable in double quotes",
+			file:       "A=\"a b\"\necho \"x $A\"\necho \"${A}y\"\n",
+			wantStdout: "x a b\na by\n",
+		},
+		{
+			name:       "variable with special characters in double quotes",
+			file:       "A='say \"$HOME\" \\'\necho \"$A\"\n",
+			wantStdout: "say \"$HOME\" \\\n",
+		},
+		{
+			name:       "variable in single quotes",
+			file:       "A=a\necho '$A' \"'$A'\"\n",
+			wantStdout: "$A 'a'\n",
+		},
+		{
+			name:       "escaped variable",
+			file:       "A=a\necho \\$A\n",
+			wantStdout: "$A\n",
+		},
+		{
+			name:       "redirection",
+			file:       "OUT=" + out + "\necho hello > $OUT\ncat $OUT\n",
+			wantStdout: "hello\n",
+		},
+		{
+			name:       "stops on failure",
+			file:       "echo a\ncat no-such-file | sort\necho b\n",
+			wantCode:   1,
+			wantStdout: "a\n",
+			wantStderr: "script: FILE:2: cat no-such-file | sort\n" +
+				"\tstage 0: cat\n" +
+				"\t\topen path no-such-file: open no-such-file: no such file or directory\n",
+		},
+		{
+			name:       "continues on failure",
+			file:       "set +e\nfalse\necho b\n",
+			wantCode:   1,
+			wantStdout: "b\n",
+			wantStderr: "script: FILE:2: false\n\tstage 0: exec(false, [])\n\t\texit status 1\n",
+		},
+		{
+			name:       "syntax error",
+			file:       "echo a |\n",
+			wantCode:   1,
+			wantStderr: "script: FILE:1: echo a |\n\tempty command in pipeline\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			require.NoError(t, os.WriteFile(path, []byte(tt.file), 0644))
+
+			var stdout, stderr bytes.Buffer
+			code := run([]string{path}, nil, &stdout, &stderr)
+			assert.Equal(t, tt.wantCode, code)
+			assert.Equal(t, tt.wantStdout, stdout.String())
+			assert.Equal(t, tt.wantStderr, string(bytes.ReplaceAll(stderr.Bytes(), []byte(path), []byte("FILE"))))
+		})
+	}
+}
+
+func TestRun_usage(t *testing.T) {
+	t.Parallel()
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 2, run(nil, nil, &stdout, &stderr))
+	assert.Equal(t, "usage: script FILE | script repl\n", stderr.String())
+}
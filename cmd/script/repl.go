@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/posener/script"
+)
+
+const replHelp = `Enter a command to add it as a stage to the pipeline, and preview the output of the pipeline.
+The preview runs the whole pipeline again, including its previous stages. If the pipeline executes
+commands that are not implemented by the script package, or redirects its output to a file, running
+it should be confirmed, since their side effects are repeated.
+Commands:
+  :undo         remove the last stage
+  :reset        remove all the stages
+  :show         print the pipeline
+  :go           print Go code of the pipeline
+  :preview <n>  set the number of previewed lines
+  :help         print this help
+  :quit         exit
+`
+
+// repl builds a pipeline interactively. Each entered line is added as a stage to the pipeline,
+// and the first lines of the output of the pipeline are previewed.
+type repl struct {
+	stages []string
+	// pending are the stages of a pipeline that is previewed once it is confirmed.
+	pending []string
+	// preview is the number of previewed lines.
+	preview int
+
+	stdout, stderr io.Writer
+}
+
+func newREPL(stdout, stderr io.Writer) *repl {
+	return &repl{preview: 10, stdout: stdout, stderr: stderr}
+}
+
+// run reads lines from the input until it ends or until the user quits.
+func (r *repl) run(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	for r.prompt(); scanner.Scan(); r.prompt() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == ":quit" {
+			return nil
+		}
+		r.exec(line)
+	}
+	return scanner.Err()
+}
+
+func (r *repl) prompt() {
+	if r.pending != nil {
+		fmt.Fprint(r.stdout, "run the pipeline? [y/N] ")
+		return
+	}
+	fmt.Fprint(r.stdout, "> ")
+}
+
+// exec executes a single line.
+func (r *repl) exec(line string) {
+	if r.pending != nil {
+		stages := r.pending
+		r.pending = nil
+		if line == "y" || line == "yes" {
+			r.previewPipeline(stages)
+		}
+		return
+	}
+	fields := strings.Fields(line)
+	switch {
+	case line == "":
+	case line == ":help":
+		fmt.Fprint(r.stdout, replHelp)
+	case line == ":undo":
+		if len(r.stages) > 0 {
+			r.stages = r.stages[:len(r.stages)-1]
+		}
+		r.show()
+	case line == ":reset":
+		r.stages = nil
+	case line == ":show":
+		r.show()
+	case line == ":go":
+		if len(r.stages) == 0 {
+			return
+		}
+		code, err := script.ParseGo(r.pipeline(r.stages))
+		if err != nil {
+			fmt.Fprintf(r.stderr, "error: %v\n", err)
+			return
+		}
+		fmt.Fprintf(r.stdout, "err := %s\n", code)
+	case fields[0] == ":preview":
+		n, err := strconv.Atoi(strings.Join(fields[1:], ""))
+		if err != nil || n < 0 {
+			fmt.Fprintln(r.stderr, "error: usage: :preview <n>")
+			return
+		}
+		r.preview = n
+	case strings.HasPrefix(line, ":"):
+		fmt.Fprintf(r.stderr, "error: unknown command %s, enter :help for help\n", fields[0])
+	default:
+		r.add(line)
+	}
+}
+
+// add adds a stage to the pipeline and previews the output. If running the pipeline has side
+// effects, it is previewed only once it is confirmed.
+func (r *repl) add(stage string) {
+	stages := append(r.stages[:len(r.stages):len(r.stages)], stage)
+	code, err := script.ParseGo(r.pipeline(stages))
+	if err != nil {
+		fmt.Fprintf(r.stderr, "error: %v\n", err)
+		return
+	}
+	if hasSideEffects(code) {
+		fmt.Fprintln(r.stdout, "the pipeline executes commands or writes to a file")
+		r.pending = stages
+		return
+	}
+	r.previewPipeline(stages)
+}
+
+// previewPipeline previews the output of a pipeline. Its stages are set as the stages of the REPL,
+// unless the pipeline fails.
+func (r *repl) previewPipeline(stages []string) {
+	s, err := script.Parse(r.pipeline(stages))
+	if err != nil {
+		fmt.Fprintf(r.stderr, "error: %v\n", err)
+		return
+	}
+	if err := s.Head(r.preview).To(r.stdout); err != nil {
+		fmt.Fprintf(r.stderr, "error: %v\n", err)
+		return
+	}
+	r.stages = stages
+}
+
+// goString matches a string literal in Go code.
+var goString = regexp.MustCompile(`"(\\.|[^"\\])*"`)
+
+// hasSideEffects returns whether the Go code of a pipeline, as returned by `script.ParseGo`,
+// executes commands or writes to a file.
+func hasSideEffects(code string) bool {
+	// Ignore the arguments of the stages.
+	code = goString.ReplaceAllString(code, `""`)
+	return strings.Contains(code, "Exec(") || !strings.HasSuffix(code, ".ToStdout()")
+}
+
+func (r *repl) show() {
+	fmt.Fprintln(r.stdout, r.pipeline(r.stages))
+}
+
+func (r *repl) pipeline(stages []string) string {
+	return strings.Join(stages, " | ")
+}
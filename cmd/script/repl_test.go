@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestREPL(t *testing.T) {
+	t.Parallel()
+
+	input := strings.Join([]string{
+		"cat ../../testdata/a.txt ../../testdata/b.txt",
+		"sort -r",
+		"no-such-command",
+		"y",
+		"grep -v a",
+		":undo",
+		":preview 1",
+		"cat",
+		"cat > out.txt",
+		"n",
+		":go",
+		":reset",
+		":show",
+		":foo",
+		":quit",
+		"echo not executed",
+	}, "\n")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"repl"}, strings.NewReader(input), &stdout, &stderr)
+	assert.Equal(t, 0, code)
+
+	assert.Equal(t, strings.Join([]string{
+		"> a\nbb\n",
+		"> bb\na\n",
+		"> the pipeline executes commands or writes to a file\n",
+		"run the pipeline? [y/N] ",
+		"> bb\n",
+		"> cat ../../testdata/a.txt ../../testdata/b.txt | sort -r\n",
+		"> ",
+		"> bb\n",
+		"> the pipeline executes commands or writes to a file\n",
+		"run the pipeline? [y/N] ",
+		`> err := script.Cat("../../testdata/a.txt", "../../testdata/b.txt").Sort(true).ToStdout()` + "\n",
+		"> ",
+		"> \n",
+		"> ",
+		"> ",
+	}, ""), stdout.String())
+
+	assert.Contains(t, stderr.String(), "stage 2 (exec(no-such-command, [])): start process")
+	assert.Contains(t, stderr.String(), "error: unknown command :foo")
+	assert.NoFileExists(t, "out.txt")
+}
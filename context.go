@@ -0,0 +1,71 @@
+package script
+
+import (
+	"context"
+	"io"
+)
+
+// WithContext binds the stream to a context. When the context is done, the current stage and all
+// the stages that are added after it are stopped: commands are killed, reading from the stream
+// returns the context error and `Close` reports it.
+//
+// Sources that need the context from the start, such as a running command, have context-taking
+// variants: `ExecContext`, `CatContext` and `LsContext`.
+func (s Stream) WithContext(ctx context.Context) Stream {
+	if s.r != nil {
+		s.r = ctxReader{ctx: ctx, r: s.r}
+	}
+	s.ctx = ctx
+	return s
+}
+
+// contextPipe is implemented by pipes that handle the cancellation of the stream's context by
+// themselves.
+type contextPipe interface {
+	Pipe
+	// withContext returns a copy of the pipe that is bound to the given context.
+	withContext(ctx context.Context) Pipe
+}
+
+// pipeContext runs a pipe that is bound to a context. Pipes that implement `contextPipe` get the
+// context, and the output of other pipes stops once the context is done. If the context is nil,
+// the pipe runs as is.
+func pipeContext(ctx context.Context, pipe Pipe, stdin io.Reader) (io.Reader, error) {
+	if ctx == nil {
+		return pipe.Pipe(stdin)
+	}
+	if cp, ok := pipe.(contextPipe); ok {
+		return cp.withContext(ctx).Pipe(stdin)
+	}
+	r, err := pipe.Pipe(stdin)
+	if r != nil {
+		r = ctxReader{ctx: ctx, r: r}
+	}
+	return r, err
+}
+
+// ctxReader is a reader that fails once its context is done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c ctxReader) Read(b []byte) (int, error) {
+	if c.ctx.Err() != nil {
+		return 0, context.Cause(c.ctx)
+	}
+	n, err := c.r.Read(b)
+	// A cancelled source may end its output early, report it as a cancellation and not as a
+	// normal EOF.
+	if err == io.EOF && c.ctx.Err() != nil {
+		err = context.Cause(c.ctx)
+	}
+	return n, err
+}
+
+func (c ctxReader) Close() error {
+	if closer, ok := c.r.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package script
+
+import (
+	"context"
+	"io"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithContext(t *testing.T) {
+	t.Parallel()
+
+	t.Run("not cancelled", func(t *testing.T) {
+		got, err := Echo("a\nb").WithContext(context.Background()).Grep(regexp.MustCompile("a")).ToString()
+		require.NoError(t, err)
+		assert.Equal(t, "a\n", got)
+	})
+
+	t.Run("cancelled modifier", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		got, err := Echo("a\nb").WithContext(ctx).Grep(regexp.MustCompile("a")).ToString()
+		assert.EqualError(t, err, "context canceled")
+		assert.Equal(t, "", got)
+	})
+
+	t.Run("cancelled custom pipe", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		got, err := Echo("a").WithContext(ctx).Through(PipeFn(func(r io.Reader) (io.Reader, error) { return r, nil })).ToString()
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, "", got)
+	})
+
+	t.Run("cancelled exec", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := ExecContext(ctx, "sleep", "10").Exec("cat").ToString()
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.EqualError(t, err, "context deadline exceeded")
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("cancelled cat", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		got, err := CatContext(ctx, "testdata/a.txt").ToString()
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, "", got)
+	})
+
+	t.Run("cancelled ls", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		files := LsContext(ctx, "testdata")
+		assert.Len(t, files.Files, 2)
+		_, err := files.ToString()
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
@@ -3,6 +3,8 @@ package script
 import (
 	"bytes"
 	"fmt"
+	"strconv"
+	"strings"
 )
 
 // Cut takes selected fields from each line. The fields are 1 based (first field is 1).
@@ -46,6 +48,21 @@ func (c Cut) Modify(line []byte) (modifed []byte, err error) {
 	return append(bytes.Join(out, c.Delim), '\n'), nil
 }
 
+func (c Cut) Shell() string {
+	if len(c.Fields) == 0 {
+		return ""
+	}
+	fields := make([]string, len(c.Fields))
+	for i, f := range c.Fields {
+		fields[i] = strconv.Itoa(f)
+	}
+	cmd := "cut -f " + strings.Join(fields, ",")
+	if len(c.Delim) > 0 {
+		cmd += " -d " + Quote(string(c.Delim))
+	}
+	return cmd
+}
+
 func (c Cut) Name() string {
 	return fmt.Sprintf("cut(%v, delim=%v)", c.Fields, c.Delim)
 }
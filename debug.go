@@ -0,0 +1,125 @@
+package script
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+// debugEnv is the environment variable that enables debugging of all the streams. If it is "1",
+// the output of the stages is written to the stderr, and otherwise it is the directory for
+// `Stream.DebugDir`.
+const debugEnv = "SCRIPT_DEBUG"
+
+// stderrDebug is the debugger of the stages when debugging to the stderr is enabled by the
+// environment.
+var stderrDebug = &debugger{w: &syncWriter{w: os.Stderr}}
+
+// Debug copies the output of each stage that is added after the current stage to w, while the
+// stream is read. Each line is prefixed with the position and the name of its stage. It shows where
+// the data of a long stream goes wrong without breaking the stream to pieces.
+//
+// Debugging of all the streams can be enabled by setting the SCRIPT_DEBUG environment variable to
+// 1, for writing to the stderr, or to a directory, like `DebugDir`.
+func (s Stream) Debug(w io.Writer) Stream {
+	s.debug = &debugger{w: &syncWriter{w: w}}
+	return s
+}
+
+// DebugDir copies the output of each stage that is added after the current stage to a file in the
+// given directory, named after the position and the name of the stage. See `Debug`.
+func (s Stream) DebugDir(dir string) Stream {
+	s.debug = &debugger{dir: dir}
+	return s
+}
+
+// getDebug returns the debugger of the stream, or nil if it is not debugged.
+func (s Stream) getDebug() *debugger {
+	if s.debug != nil {
+		return s.debug
+	}
+	switch env := os.Getenv(debugEnv); env {
+	case "", "0":
+		return nil
+	case "1":
+		return stderrDebug
+	default:
+		return &debugger{dir: env}
+	}
+}
+
+// debugger creates the writers for the output of debugged stages.
+type debugger struct {
+	// w, if not nil, is where the output of all the stages is written.
+	w io.Writer
+	// dir is the directory of the output files of the stages, if w is nil.
+	dir string
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// open returns a writer for the output of a stage.
+func (d *debugger) open(index int, name string) (io.WriteCloser, error) {
+	prefix := fmt.Sprintf("%d-%s", index, name)
+	if d.w != nil {
+		return &prefixWriter{w: d.w, prefix: []byte("[" + prefix + "] ")}, nil
+	}
+	if err := os.MkdirAll(d.dir, 0o775); err != nil {
+		return nil, fmt.Errorf("create debug directory: %w", err)
+	}
+	f, err := os.Create(filepath.Join(d.dir, unsafeFileChars.ReplaceAllString(prefix, "_")+".txt"))
+	if err != nil {
+		return nil, fmt.Errorf("create debug file: %w", err)
+	}
+	return f, nil
+}
+
+// prefixWriter writes lines with a prefix. Each line is written in a single write, and an
+// unterminated last line is written on close.
+type prefixWriter struct {
+	w      io.Writer
+	prefix []byte
+	// partial is an unterminated line that was not written yet.
+	partial []byte
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	n := len(b)
+	for {
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			p.partial = append(p.partial, b...)
+			return n, nil
+		}
+		line := append(append(append([]byte{}, p.prefix...), p.partial...), b[:i+1]...)
+		p.partial = p.partial[:0]
+		b = b[i+1:]
+		if _, err := p.w.Write(line); err != nil {
+			return n, err
+		}
+	}
+}
+
+func (p *prefixWriter) Close() error {
+	if len(p.partial) == 0 {
+		return nil
+	}
+	_, err := p.Write([]byte{'\n'})
+	return err
+}
+
+// syncWriter is a writer that can be written concurrently.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(b []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(b)
+}
@@ -0,0 +1,62 @@
+package script
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebug(t *testing.T) {
+	t.Parallel()
+
+	var debug bytes.Buffer
+	out, err := Echo("a\t1\nb\t2\na\t3").Debug(&debug).
+		Grep(regexp.MustCompile("a")).
+		Cut(2).
+		ToString()
+	require.NoError(t, err)
+	assert.Equal(t, "1\n3\n", out)
+
+	lines := strings.Split(strings.TrimSpace(debug.String()), "\n")
+	sort.Stable(sort.StringSlice(lines))
+	assert.Equal(t, []string{
+		"[1-grep(a, invert=false)] a\t1",
+		"[1-grep(a, invert=false)] a\t3",
+		"[2-cut([2], delim=[])] 1",
+		"[2-cut([2], delim=[])] 3",
+	}, lines)
+}
+
+func TestDebugDir(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	err := Echo("a\t1\nb\t2").DebugDir(dir).Grep(regexp.MustCompile("a")).Cut(2).Discard()
+	require.NoError(t, err)
+
+	grep, err := os.ReadFile(filepath.Join(dir, "1-grep_a_invert_false_.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "a\t1\n", string(grep))
+	cut, err := os.ReadFile(filepath.Join(dir, "2-cut_2_delim_.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "1\n", string(cut))
+}
+
+func TestDebugEnv(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(debugEnv, dir)
+
+	err := Echo("hello").Discard()
+	require.NoError(t, err)
+
+	echo, err := os.ReadFile(filepath.Join(dir, "0-echo.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", string(echo))
+}
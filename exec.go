@@ -1,17 +1,35 @@
 package script
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
+// execStderrTail is the number of bytes from the end of the stderr of a command that are kept for
+// the `ExecError`.
+const execStderrTail = 4 << 10
+
 // Exec executes a command and returns a stream of the stdout of the command.
 func Exec(cmd string, args ...string) Stream {
 	return From("empty", nil).Through(exe{cmd: cmd, args: args})
 }
 
+// ExecContext executes a command that is killed when the given context is done, and returns a
+// stream of the stdout of the command. The returned stream is bound to the context.
+func ExecContext(ctx context.Context, cmd string, args ...string) Stream {
+	return From("empty", nil).WithContext(ctx).Through(exe{cmd: cmd, args: args})
+}
+
 // ExecHandleStderr executes a command, returns a stream of the stdout of the command and enable
 // collecting the stderr of the command.
 //
@@ -21,7 +39,13 @@ func Exec(cmd string, args ...string) Stream {
 // `stderr`. Writing it to stderr can be done by providing `os.Stderr` as `stderr`. Logging it
 // to a file can be done by providing an `os.File` as the `stderr`.
 func ExecHandleStderr(stderr io.Writer, cmd string, args ...string) Stream {
-	return From("empty", nil).Through(exe{cmd: cmd, args: args, stderr: stderr})
+	return ExecWith(ExecOptions{Stderr: stderr}, cmd, args...)
+}
+
+// ExecWith executes a command with the given options and returns a stream of the stdout of the
+// command.
+func ExecWith(opts ExecOptions, cmd string, args ...string) Stream {
+	return From("empty", nil).ExecWith(opts, cmd, args...)
 }
 
 // Exec executes a command and returns a stream of the stdout of the command.
@@ -34,50 +58,427 @@ func (s Stream) Exec(cmd string, args ...string) Stream {
 //
 // If the stderr is nil, it will be ignored.
 func (s Stream) ExecHandleStderr(stderr io.Writer, cmd string, args ...string) Stream {
-	return s.Through(exe{cmd: cmd, args: args, stderr: stderr})
+	return s.ExecWith(ExecOptions{Stderr: stderr}, cmd, args...)
+}
+
+// ExecWith executes a command with the given options, and returns a stream of the stdout of the
+// command. The current stream is piped to the stdin of the command, unless `opts.Stdin` is set.
+func (s Stream) ExecWith(opts ExecOptions, cmd string, args ...string) Stream {
+	return s.Through(exe{cmd: cmd, args: args, opts: opts})
+}
+
+// ExecOptions are options for executing a command.
+type ExecOptions struct {
+	// Dir is the working directory of the command. If empty, the command runs in the current
+	// directory.
+	Dir string
+	// Env is the environment of the command, each entry in the form of "key=value". If nil, the
+	// command inherits the environment of the current process.
+	Env []string
+	// MergeEnv, when set, adds Env to the environment of the current process instead of
+	// replacing it. Entries in Env override variables of the current process with the same key.
+	MergeEnv bool
+	// Stdin, if not nil, is used as the stdin of the command instead of the stream.
+	Stdin io.Reader
+	// Stderr, if not nil, collects the stderr of the command. See `ExecHandleStderr`.
+	Stderr io.Writer
+	// ExtraFiles are additional open files that are passed to the command. Entry i becomes file
+	// descriptor 3+i.
+	ExtraFiles []*os.File
+	// MergeStderr writes the stderr of the command to the stream together with its stdout, like
+	// `2>&1` in a shell. Each line is written as a whole, such that lines from stdout and stderr
+	// are not mixed.
+	MergeStderr bool
+	// GracePeriod is the time the command is given to exit after it is sent SIGTERM, when the stream
+	// is closed before the command finished or when the stream's context is done. After the grace
+	// period the command is killed. If zero, the command is killed immediately.
+	GracePeriod time.Duration
+	// ProcessGroup runs the command in its own process group, and the signals that stop the command
+	// are sent to all the processes in the group, such as the children of a shell script. Signals
+	// that the terminal sends to its foreground process group, for example when Ctrl+C is pressed,
+	// don't reach a command in its own group unless they are forwarded with `ForwardSignals`.
+	// Commands that read from the terminal, such as `ssh` or `sudo`, should not run in their own
+	// process group, since they are stopped when they read from it.
+	ProcessGroup bool
+	// OKExitCodes are exit codes of the command that are not considered as failures, in addition
+	// to 0. For example, `diff` and `grep` exit with 1 as a normal result.
+	OKExitCodes []int
+	// Hook, if not nil, is called with the command just before it is started. It can be used to
+	// set fields of the command which are not covered by the other options.
+	Hook func(*exec.Cmd)
 }
 
 type exe struct {
-	cmd    string
-	args   []string
-	stderr io.Writer
+	cmd  string
+	args []string
+	opts ExecOptions
+	// ctx, when not nil, kills the command once it is done.
+	ctx context.Context
+	// stderrOut, when not nil, is the output of the stderr stream of `ExecSplit`.
+	stderrOut *bufPipe
+	// dryRun, when not nil, reports the command to it instead of running it.
+	dryRun io.Writer
+	// timer, when not nil, is started when the command is started.
+	timer *stageTimer
+}
+
+func (e exe) withContext(ctx context.Context) Pipe {
+	e.ctx = ctx
+	return e
+}
+
+func (e exe) withTimer(t *stageTimer) Pipe {
+	e.timer = t
+	return e
 }
 
 func (e exe) Name() string {
 	return fmt.Sprintf("exec(%v, %+v)", e.cmd, e.args)
 }
 
+func (e exe) Shell() string {
+	// Options that can't be expressed in a shell pipeline.
+	if e.opts.Stdin != nil || len(e.opts.ExtraFiles) > 0 || e.opts.Hook != nil {
+		return ""
+	}
+	cmd := quoteAll(append([]string{e.cmd}, e.args...))
+	if e.opts.Env != nil {
+		env := "env -i"
+		if e.opts.MergeEnv {
+			env = "env"
+		}
+		cmd = env + " " + quoteAll(e.opts.Env) + " " + cmd
+	}
+	if e.opts.MergeStderr {
+		cmd += " 2>&1"
+	}
+	if e.opts.Dir != "" {
+		cmd = "(cd " + Quote(e.opts.Dir) + " && " + cmd + ")"
+	}
+	return cmd
+}
+
+func (e exe) withDryRun(w io.Writer) Pipe {
+	e.dryRun = w
+	return e
+}
+
 func (e exe) Pipe(stdin io.Reader) (io.Reader, error) {
-	cmd := exec.Command(e.cmd, e.args...)
-	var merr error
+	if e.dryRun != nil {
+		if e.stderrOut != nil {
+			e.stderrOut.CloseWrite()
+		}
+		fmt.Fprintf(e.dryRun, "dry-run: %s\n", describe(e))
+		return strings.NewReader(""), nil
+	}
+	builtin, err := lookupBuiltin(e.cmd)
+	if err != nil {
+		if e.stderrOut != nil {
+			e.stderrOut.CloseWrite()
+		}
+		return strings.NewReader(""), err
+	}
+	if builtin != nil {
+		return e.pipeBuiltin(builtin, stdin)
+	}
+	if c := activeCassette.Load(); c != nil {
+		return c.pipe(e, stdin)
+	}
+	return e.run(stdin)
+}
+
+// run runs the command of e in a new process.
+func (e exe) run(stdin io.Reader) (io.Reader, error) {
+	var (
+		cmd  *exec.Cmd
+		merr error
+	)
+	if e.ctx != nil {
+		cmd = exec.CommandContext(e.ctx, e.cmd, e.args...)
+	} else {
+		cmd = exec.Command(e.cmd, e.args...)
+	}
 
 	// Pipe previous stdin if available.
-	if stdin != nil {
+	if e.opts.Stdin != nil {
+		cmd.Stdin = e.opts.Stdin
+	} else if stdin != nil {
 		cmd.Stdin = stdin
 	}
 
+	if e.opts.ProcessGroup {
+		setProcessGroup(cmd)
+	}
+	cmd.Dir = e.opts.Dir
+	cmd.ExtraFiles = e.opts.ExtraFiles
+	if e.opts.Env != nil {
+		cmd.Env = e.opts.Env
+		if e.opts.MergeEnv {
+			cmd.Env = append(os.Environ(), e.opts.Env...)
+		}
+	}
+
 	// Pipe stdout to the current command output.
 	cmdOut, err := cmd.StdoutPipe()
 	if err != nil {
 		merr = errors.Join(merr, fmt.Errorf("pipe stdout: %w", err))
 	}
 
-	if e.stderr == nil {
-		e.stderr = io.Discard
+	// Keep the tail of stderr for the error report, in addition to the given writer.
+	tail := &tailWriter{limit: execStderrTail}
+	var stderr io.Writer = tail
+	if e.opts.Stderr != nil {
+		stderr = io.MultiWriter(e.opts.Stderr, tail)
+	}
+
+	// When stderr is streamed, it is copied by the stage instead of by the command.
+	streamStderr := e.opts.MergeStderr || e.stderrOut != nil
+	var cmdErr io.ReadCloser
+	if streamStderr {
+		cmdErr, err = cmd.StderrPipe()
+		if err != nil {
+			merr = errors.Join(merr, fmt.Errorf("pipe stderr: %w", err))
+		}
+	} else {
+		cmd.Stderr = stderr
+	}
+
+	if e.opts.Hook != nil {
+		e.opts.Hook(cmd)
 	}
-	cmd.Stderr = e.stderr
 
 	// start the process
+	start := time.Now()
+	g := newGroup(cmd, e.opts.GracePeriod, e.opts.ProcessGroup)
+	var cancelled atomic.Bool
+	if e.ctx != nil {
+		cmd.Cancel = func() error {
+			cancelled.Store(true)
+			return g.terminate()
+		}
+	}
 	err = cmd.Start()
 	if err != nil {
 		merr = errors.Join(merr, fmt.Errorf("start process: %w", err))
+	} else {
+		running.add(g)
+		if e.timer != nil {
+			e.timer.start()
+		}
+	}
+
+	// Copy the streamed outputs of the command. The copying must be done before waiting for the
+	// command.
+	var (
+		output  io.Reader = cmdOut
+		merged  *io.PipeReader
+		copying sync.WaitGroup
+	)
+	switch {
+	case err != nil:
+		if e.stderrOut != nil {
+			e.stderrOut.CloseWrite()
+		}
+	case e.opts.MergeStderr:
+		var w *io.PipeWriter
+		merged, w = io.Pipe()
+		output = merged
+		var (
+			outTail, errTail []byte
+			lines            sync.WaitGroup
+		)
+		lines.Add(2)
+		copying.Add(1)
+		go func() {
+			defer lines.Done()
+			outTail, _ = copyLines(w, cmdOut)
+		}()
+		go func() {
+			defer lines.Done()
+			errTail, _ = copyLines(io.MultiWriter(stderr, w), cmdErr)
+		}()
+		go func() {
+			defer copying.Done()
+			lines.Wait()
+			// Unterminated last lines are written when both outputs are done. If both have one,
+			// they are separated by a new line.
+			if len(outTail) > 0 && len(errTail) > 0 {
+				outTail = append(outTail, '\n')
+			}
+			w.Write(outTail)
+			stderr.Write(errTail)
+			w.Write(errTail)
+			w.Close()
+		}()
+	case e.stderrOut != nil:
+		copying.Add(1)
+		go func() {
+			defer copying.Done()
+			io.Copy(io.MultiWriter(stderr, e.stderrOut), cmdErr)
+			e.stderrOut.CloseWrite()
+		}()
+	}
+
+	stdout := &eofReader{r: output}
+	var out io.Reader = stdout
+	if e.ctx != nil {
+		// A killed command closes its output, make sure the reader sees the cancellation and
+		// not a normal EOF.
+		out = ctxReader{ctx: e.ctx, r: stdout}
 	}
 	return readcloser{
-		Reader: cmdOut,
-		Closer: closerFn(func() error { return cmd.Wait() }),
+		Reader: out,
+		Closer: closerFn(func() error {
+			// If the output of the command was read but not to its end, the following stages
+			// stopped early or the stream was closed before it was done. Like a shell does with
+			// SIGPIPE, terminate the command, which is not considered a failure.
+			stoppedEarly := stdout.read.Load() && !stdout.eof.Load() && cmd.Process != nil
+			var err error
+			switch {
+			case stoppedEarly:
+				g.terminate()
+				if merged != nil {
+					merged.Close()
+				}
+				// The rest of the outputs is not needed. Waiting for the command closes them,
+				// which stops the copying even if processes that the command started in the
+				// background keep them open.
+				err = g.wait()
+				copying.Wait()
+			case !stdout.read.Load() && cmd.Process != nil:
+				// The output was never read, wait for the command to finish and discard its
+				// output, such that it does not block on writing it.
+				io.Copy(io.Discard, output)
+				fallthrough
+			default:
+				copying.Wait()
+				err = g.wait()
+			}
+			// A command that was stopped since its context is done did not fail, the stream
+			// reports the cancellation or the timeout.
+			if cancelled.Load() {
+				return nil
+			}
+			// An error of reading the stdin that is an error of a previous stage is reported by
+			// that stage.
+			var stageErr *StageError
+			if errors.As(err, &stageErr) {
+				return nil
+			}
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				if stoppedEarly && !exitErr.Exited() {
+					return nil
+				}
+				if e.okExitCode(exitErr.ExitCode()) {
+					return nil
+				}
+				return newExecError(e, exitErr, time.Since(start), tail.Bytes())
+			}
+			return err
+		}),
 	}, merr
 }
 
+func (e exe) okExitCode(code int) bool {
+	for _, ok := range e.opts.OKExitCodes {
+		if code == ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ExecError is returned by `Close` of a stream with a command that failed. It describes the
+// failure of the command.
+type ExecError struct {
+	// Cmd is the command that was executed.
+	Cmd string
+	// Args are the arguments of the command.
+	Args []string
+	// ExitCode is the exit code of the command, or -1 if the command was terminated by a signal.
+	ExitCode int
+	// Signal is the signal that terminated the command, or nil if it exited normally.
+	Signal os.Signal
+	// Duration is the time the command ran.
+	Duration time.Duration
+	// Stderr is the end of the stderr of the command. It is collected whether or not the stderr
+	// of the command was handled.
+	Stderr []byte
+	// Err is the error returned by the command.
+	Err error
+}
+
+func newExecError(e exe, err *exec.ExitError, d time.Duration, stderr []byte) *ExecError {
+	execErr := &ExecError{
+		Cmd:      e.cmd,
+		Args:     e.args,
+		ExitCode: err.ExitCode(),
+		Duration: d,
+		Stderr:   stderr,
+		Err:      err,
+	}
+	if ws, ok := err.Sys().(interface {
+		Signaled() bool
+		Signal() syscall.Signal
+	}); ok && ws.Signaled() {
+		execErr.Signal = ws.Signal()
+	}
+	return execErr
+}
+
+// Error returns the exit status of the command together with the last line of its stderr.
+func (e *ExecError) Error() string {
+	msg := e.Err.Error()
+	if line := lastLine(e.Stderr); len(line) > 0 {
+		msg += ": " + string(line)
+	}
+	return msg
+}
+
+func (e *ExecError) Unwrap() error { return e.Err }
+
+func lastLine(b []byte) []byte {
+	b = bytes.TrimRight(b, "\n")
+	if i := bytes.LastIndexByte(b, '\n'); i >= 0 {
+		b = b[i+1:]
+	}
+	return b
+}
+
+// eofReader records whether its underlying reader was read, and whether it was fully read.
+type eofReader struct {
+	r    io.Reader
+	read atomic.Bool
+	eof  atomic.Bool
+}
+
+func (e *eofReader) Read(b []byte) (int, error) {
+	e.read.Store(true)
+	n, err := e.r.Read(b)
+	if err == io.EOF {
+		e.eof.Store(true)
+	}
+	return n, err
+}
+
+// tailWriter keeps the last bytes that were written to it, up to a limit.
+type tailWriter struct {
+	limit int
+	buf   []byte
+}
+
+func (t *tailWriter) Write(b []byte) (int, error) {
+	n := len(b)
+	t.buf = append(t.buf, b...)
+	if len(t.buf) > t.limit {
+		t.buf = append(t.buf[:0], t.buf[len(t.buf)-t.limit:]...)
+	}
+	return n, nil
+}
+
+func (t *tailWriter) Bytes() []byte { return t.buf }
+
 type closerFn func() error
 
 func (f closerFn) Close() error { return f() }
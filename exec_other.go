@@ -0,0 +1,20 @@
+//go:build !unix
+
+package script
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup is not supported on this platform, the command runs in the process group of the
+// current process.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// signalGroup sends a signal to a process. Process groups are not supported on this platform.
+func signalGroup(p *os.Process, sig os.Signal) error {
+	if sig == os.Kill {
+		return p.Kill()
+	}
+	return p.Signal(sig)
+}
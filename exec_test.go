@@ -2,7 +2,14 @@ package script
 
 import (
 	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -40,4 +47,124 @@ func TestExec(t *testing.T) {
 		assert.Equal(t, "a\n", stdout) // Content of testdata/a.txt
 		assert.Equal(t, "cat: no-such-file: No such file or directory\n", stderr.String())
 	})
+
+	t.Run("stopped early", func(t *testing.T) {
+		stdout, err := Exec("yes").Head(2).ToString()
+
+		require.NoError(t, err)
+		assert.Equal(t, "y\ny\n", stdout)
+	})
+
+	t.Run("closed early", func(t *testing.T) {
+		s := Exec("yes").Exec("cat")
+		b := make([]byte, 2)
+		_, err := io.ReadFull(s, b)
+		require.NoError(t, err)
+		assert.NoError(t, s.Close())
+	})
+
+	t.Run("closed without reading", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "f")
+		assert.NoError(t, Exec("sh", "-c", "sleep 0.2; echo out; touch "+path).Close())
+		assert.FileExists(t, path)
+
+		assert.EqualError(t, Exec("false").Close(), "stage 0 (exec(false, [])): exit status 1")
+	})
+
+	t.Run("closed twice", func(t *testing.T) {
+		s := Exec("echo", "hi")
+		out, err := s.ToString()
+		require.NoError(t, err)
+		assert.Equal(t, "hi\n", out)
+		assert.NoError(t, s.Close())
+
+		s = Exec("false")
+		assert.Error(t, s.Discard())
+		assert.Error(t, s.Close())
+	})
+
+	t.Run("exec error", func(t *testing.T) {
+		_, err := Exec("sh", "-c", "echo first >&2; echo oops >&2; exit 3").ToString()
+
+		var execErr *ExecError
+		require.True(t, errors.As(err, &execErr))
+		assert.Equal(t, "sh", execErr.Cmd)
+		assert.Equal(t, 3, execErr.ExitCode)
+		assert.Nil(t, execErr.Signal)
+		assert.Equal(t, "first\noops\n", string(execErr.Stderr))
+		assert.Greater(t, execErr.Duration, time.Duration(0))
+		assert.EqualError(t, execErr, "exit status 3: oops")
+	})
+
+	t.Run("exec error stderr tail", func(t *testing.T) {
+		var stderr bytes.Buffer
+		_, err := ExecHandleStderr(&stderr, "sh", "-c", "head -c 10000 /dev/zero | tr '\\0' x >&2; exit 1").ToString()
+
+		var execErr *ExecError
+		require.True(t, errors.As(err, &execErr))
+		assert.Equal(t, 10000, stderr.Len())
+		assert.Equal(t, strings.Repeat("x", execStderrTail), string(execErr.Stderr))
+	})
+}
+
+func TestExecWith(t *testing.T) {
+	t.Parallel()
+
+	t.Run("dir", func(t *testing.T) {
+		stdout, err := ExecWith(ExecOptions{Dir: "testdata"}, "cat", "a.txt").ToString()
+
+		require.NoError(t, err)
+		assert.Equal(t, "a\n", stdout)
+	})
+
+	t.Run("env", func(t *testing.T) {
+		stdout, err := ExecWith(ExecOptions{Env: []string{"FOO=foo"}}, "sh", "-c", "echo $FOO$HOME").ToString()
+
+		require.NoError(t, err)
+		assert.Equal(t, "foo\n", stdout)
+	})
+
+	t.Run("merge env", func(t *testing.T) {
+		opts := ExecOptions{Env: []string{"FOO=foo", "HOME=home"}, MergeEnv: true}
+		stdout, err := ExecWith(opts, "sh", "-c", "echo $FOO$HOME$PATH").ToString()
+
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(stdout, "foohome/"))
+	})
+
+	t.Run("stdin", func(t *testing.T) {
+		stdout, err := Echo("ignored").ExecWith(ExecOptions{Stdin: strings.NewReader("hello")}, "cat").ToString()
+
+		require.NoError(t, err)
+		assert.Equal(t, "hello", stdout)
+	})
+
+	t.Run("extra files", func(t *testing.T) {
+		f, err := os.Open("testdata/b.txt")
+		require.NoError(t, err)
+		defer f.Close()
+
+		stdout, err := ExecWith(ExecOptions{ExtraFiles: []*os.File{f}}, "sh", "-c", "cat <&3").ToString()
+
+		require.NoError(t, err)
+		assert.Equal(t, "bb\n", stdout)
+	})
+
+	t.Run("ok exit codes", func(t *testing.T) {
+		stdout, err := ExecWith(ExecOptions{OKExitCodes: []int{1}}, "sh", "-c", "echo diff; exit 1").ToString()
+
+		require.NoError(t, err)
+		assert.Equal(t, "diff\n", stdout)
+
+		_, err = ExecWith(ExecOptions{OKExitCodes: []int{1}}, "sh", "-c", "exit 2").ToString()
+		assert.Error(t, err)
+	})
+
+	t.Run("hook", func(t *testing.T) {
+		hook := func(cmd *exec.Cmd) { cmd.Args = append(cmd.Args, "hooked") }
+		stdout, err := ExecWith(ExecOptions{Hook: hook}, "echo").ToString()
+
+		require.NoError(t, err)
+		assert.Equal(t, "hooked\n", stdout)
+	})
 }
@@ -0,0 +1,86 @@
+//go:build unix
+
+package script
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExec_processGroup(t *testing.T) {
+	t.Parallel()
+
+	t.Run("same process group by default", func(t *testing.T) {
+		out, err := Exec("sh", "-c", "ps -o pgid= $$").ToString()
+		require.NoError(t, err)
+		assert.Equal(t, strconv.Itoa(syscall.Getpgrp()), strings.TrimSpace(out))
+	})
+
+	t.Run("children are killed", func(t *testing.T) {
+		s := ExecWith(ExecOptions{ProcessGroup: true}, "sh", "-c", "sleep 10 & echo $!; wait")
+		line, err := bufio.NewReader(s).ReadString('\n')
+		require.NoError(t, err)
+		pid, err := strconv.Atoi(strings.TrimSpace(line))
+		require.NoError(t, err)
+
+		require.NoError(t, s.Close())
+		assert.Eventually(t, func() bool { return !alive(pid) }, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("grace period", func(t *testing.T) {
+		opts := ExecOptions{GracePeriod: 5 * time.Second}
+		s := ExecWith(opts, "sh", "-c", `trap "exit 0" TERM; echo ready; while :; do sleep 0.01; done`)
+		_, err := bufio.NewReader(s).ReadString('\n')
+		require.NoError(t, err)
+
+		start := time.Now()
+		assert.NoError(t, s.Close())
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("grace period expired", func(t *testing.T) {
+		opts := ExecOptions{GracePeriod: 100 * time.Millisecond}
+		s := ExecWith(opts, "sh", "-c", `trap "" TERM; echo ready; while :; do sleep 0.01; done`)
+		_, err := bufio.NewReader(s).ReadString('\n')
+		require.NoError(t, err)
+
+		assert.NoError(t, s.Close())
+	})
+}
+
+// Not parallel, since the forwarded signal reaches all the running commands.
+func TestForwardSignals(t *testing.T) {
+	stop := ForwardSignals(syscall.SIGUSR1)
+	defer stop()
+
+	s := ExecWith(ExecOptions{ProcessGroup: true}, "sh", "-c", `trap "echo got; exit 0" USR1; echo ready; while :; do sleep 0.01; done`)
+	r := bufio.NewReader(s)
+	line, err := r.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "ready\n", line)
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+
+	line, err = r.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "got\n", line)
+	assert.NoError(t, s.Close())
+}
+
+// alive checks if a process is running. Zombie processes are not considered as running.
+func alive(pid int) bool {
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return syscall.Kill(pid, 0) == nil
+	}
+	fields := strings.Fields(string(stat))
+	return len(fields) > 2 && fields[2] != "Z"
+}
@@ -2,6 +2,7 @@ package script
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"os"
 	"strings"
@@ -9,25 +10,92 @@ import (
 
 // From creates a stream from a reader.
 func From(name string, r io.Reader) Stream {
-	return Stream{stage: name, r: r}
+	return Stream{stage: name, r: r}.measure(nil)
 }
 
-// Writer creates a stream from a function that writes to a writer.
+// Writer creates a stream from a function that writes to a writer. The function is called on the
+// first read of the stream, and its error is returned by `Close`. The written data is held in memory,
+// use `StreamingWriter` for large outputs.
 func Writer(name string, writer func(io.Writer) error) Stream {
-	b := bytes.NewBuffer(nil)
-	err := writer(b)
-	return Stream{stage: name, r: b, err: err}
+	r := &lazyReader{create: func() (io.Reader, error) {
+		b := bytes.NewBuffer(nil)
+		err := writer(b)
+		return b, err
+	}}
+	return Stream{stage: name, r: r}.measure(nil)
+}
+
+// StreamingWriter creates a stream from a function that writes to a writer, like `Writer`, without
+// holding the written data in memory. The function runs in a goroutine from the first read of the
+// stream, and each write blocks until the data is read. Its error is returned by `Close`.
+//
+// When the stream is closed before the function finished, the writes of the function fail with
+// `io.ErrClosedPipe`, and the function should return. `Close` waits for it to return, and does not
+// report the failed writes as an error.
+func StreamingWriter(name string, writer func(io.Writer) error) Stream {
+	return Stream{stage: name, r: &writerReader{writer: writer}}.measure(nil)
+}
+
+// writerReader reads the data that a function writes, while the function runs in a goroutine.
+type writerReader struct {
+	writer  func(io.Writer) error
+	started bool
+	r       *io.PipeReader
+	// eof is set when all the written data was read.
+	eof  bool
+	done chan struct{}
+	err  error
+}
+
+func (w *writerReader) Read(b []byte) (int, error) {
+	if !w.started {
+		w.start()
+	}
+	n, err := w.r.Read(b)
+	if err == io.EOF {
+		w.eof = true
+	}
+	return n, err
+}
+
+func (w *writerReader) start() {
+	w.started = true
+	r, pw := io.Pipe()
+	w.r = r
+	w.done = make(chan struct{})
+	go func() {
+		defer close(w.done)
+		w.err = w.writer(pw)
+		pw.Close()
+	}()
+}
+
+func (w *writerReader) Close() error {
+	if !w.started {
+		return nil
+	}
+	stoppedEarly := !w.eof
+	w.r.Close()
+	<-w.done
+	if stoppedEarly && errors.Is(w.err, io.ErrClosedPipe) {
+		return nil
+	}
+	return w.err
 }
 
 // Stdin starts a stream from stdin.
 func Stdin() Stream {
 	stdin := io.NopCloser(os.Stdin) // Prevent closing of stdin.
-	return From("stdin", stdin)
+	s := From("stdin", stdin)
+	s.shell = "cat"
+	return s
 }
 
 // Echo writes to stdout.
 //
 // Shell command: `echo <s>`
 func Echo(s string) Stream {
-	return From("echo", strings.NewReader(s+"\n"))
+	echo := From("echo", strings.NewReader(s+"\n"))
+	echo.shell = "printf '%s\\n' " + Quote(s)
+	return echo
 }
@@ -54,3 +54,60 @@ func TestWriter_failure(t *testing.T) {
 	_, err := Writer("fail", func(w io.Writer) error { return errors.New("failed") }).ToString()
 	assert.Error(t, err)
 }
+
+func TestWriter_lazy(t *testing.T) {
+	t.Parallel()
+	var called bool
+	s := Writer("lazy", func(w io.Writer) error {
+		called = true
+		_, err := io.WriteString(w, "foo\n")
+		return err
+	})
+	assert.False(t, called)
+
+	got, err := s.ToString()
+	require.NoError(t, err)
+	assert.True(t, called)
+	assert.Equal(t, "foo\n", got)
+}
+
+func TestStreamingWriter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("write", func(t *testing.T) {
+		got, err := StreamingWriter("json", func(w io.Writer) error { return json.NewEncoder(w).Encode("foo") }).ToString()
+		require.NoError(t, err)
+		assert.Equal(t, "\"foo\"\n", got)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		got, err := StreamingWriter("fail", func(w io.Writer) error {
+			io.WriteString(w, "foo\n")
+			return errors.New("failed")
+		}).ToString()
+		assert.EqualError(t, err, "stage 0 (fail): failed")
+		assert.Equal(t, "foo\n", got)
+	})
+
+	t.Run("stopped early", func(t *testing.T) {
+		got, err := StreamingWriter("infinite", func(w io.Writer) error {
+			for {
+				if _, err := io.WriteString(w, "y\n"); err != nil {
+					return err
+				}
+			}
+		}).Head(2).ToString()
+		require.NoError(t, err)
+		assert.Equal(t, "y\ny\n", got)
+	})
+
+	t.Run("not read", func(t *testing.T) {
+		called := false
+		s := StreamingWriter("lazy", func(w io.Writer) error {
+			called = true
+			return nil
+		})
+		require.NoError(t, s.Close())
+		assert.False(t, called)
+	})
+}
@@ -0,0 +1,128 @@
+package script
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GraphFormat is the format of a graph of a stream.
+type GraphFormat int
+
+const (
+	// GraphText renders the graph as indented text, with a line for each stage.
+	GraphText GraphFormat = iota
+	// GraphDOT renders the graph in the Graphviz DOT language.
+	GraphDOT
+)
+
+// Graph renders the stages of the stream, from its source to its current stage. Each stage is
+// described by its name and, once it produced output or was closed, its measurements (see
+// `Stream.Stats`). A stream of the stderr of `ExecSplit` is connected to the command it came from.
+//
+// For example, a diagram of a stream can be created with:
+//
+//	dot := s.Graph(script.GraphDOT)
+//	err := script.Echo(dot).Exec("dot", "-Tsvg").ToFile("stream.svg")
+func (s Stream) Graph(format GraphFormat) string {
+	nodes := s.graphNodes()
+	switch format {
+	case GraphDOT:
+		return dotGraph(nodes)
+	default:
+		return textGraph(nodes)
+	}
+}
+
+// graphNode is a stage in a graph.
+type graphNode struct {
+	label string
+	stats string
+	// edge is the label of the edge from the previous node.
+	edge string
+}
+
+// graphNodes returns the nodes of the stages that lead to the current stage, from the first one.
+func (s Stream) graphNodes() []graphNode {
+	var nodes []graphNode
+	for cur, edge := &s, ""; cur != nil; {
+		// A stream without a reader, such as the source of `Exec`, is not a stage.
+		if cur.r != nil {
+			nodes = append(nodes, graphNode{label: cur.stage, stats: cur.stats.describe(), edge: edge})
+		}
+		if cur.parent != nil {
+			cur, edge = cur.parent, ""
+		} else {
+			cur, edge = cur.source, "stderr"
+		}
+	}
+	for i, j := 0, len(nodes)-1; i < j; i, j = i+1, j-1 {
+		nodes[i], nodes[j] = nodes[j], nodes[i]
+	}
+	// Edges were collected on the node they lead from.
+	for i := len(nodes) - 1; i > 0; i-- {
+		nodes[i].edge = nodes[i-1].edge
+	}
+	if len(nodes) > 0 {
+		nodes[0].edge = ""
+	}
+	return nodes
+}
+
+func textGraph(nodes []graphNode) string {
+	var b strings.Builder
+	for i, node := range nodes {
+		b.WriteString(strings.Repeat("  ", i))
+		if node.edge != "" {
+			b.WriteString(node.edge + ": ")
+		}
+		b.WriteString(node.label)
+		if node.stats != "" {
+			b.WriteString(" (" + node.stats + ")")
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func dotGraph(nodes []graphNode) string {
+	var b strings.Builder
+	b.WriteString("digraph stream {\n\trankdir=LR;\n\tnode [shape=box];\n")
+	for i, node := range nodes {
+		label := node.label
+		if node.stats != "" {
+			label += "\n" + node.stats
+		}
+		fmt.Fprintf(&b, "\tn%d [label=%s];\n", i, strconv.Quote(label))
+	}
+	for i := 1; i < len(nodes); i++ {
+		fmt.Fprintf(&b, "\tn%d -> n%d", i-1, i)
+		if nodes[i].edge != "" {
+			fmt.Fprintf(&b, " [label=%s]", strconv.Quote(nodes[i].edge))
+		}
+		b.WriteString(";\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// describe returns a short description of the measurements of a stage, or an empty string if the
+// stage did not produce output and was not closed.
+func (s *stageStats) describe() string {
+	if s == nil {
+		return ""
+	}
+	stats := s.get("")
+	s.mu.Lock()
+	started := stats.BytesOut > 0 || s.closed
+	s.mu.Unlock()
+	if !started {
+		return ""
+	}
+	desc := fmt.Sprintf("%d B, %d lines, %s", stats.BytesOut, stats.LinesOut, stats.Duration.Round(time.Microsecond))
+	if stats.Err != nil {
+		desc += ", error: " + stats.Err.Error()
+	}
+	return desc
+}
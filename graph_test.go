@@ -0,0 +1,55 @@
+package script
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph(t *testing.T) {
+	t.Parallel()
+
+	s := Echo("a\nb").Grep(regexp.MustCompile("a")).Head(1)
+
+	t.Run("text", func(t *testing.T) {
+		assert.Equal(t, "echo\n  grep(a, invert=false)\n    head(1)\n", s.Graph(GraphText))
+	})
+
+	t.Run("dot", func(t *testing.T) {
+		want := `digraph stream {
+	rankdir=LR;
+	node [shape=box];
+	n0 [label="echo"];
+	n1 [label="grep(a, invert=false)"];
+	n2 [label="head(1)"];
+	n0 -> n1;
+	n1 -> n2;
+}
+`
+		assert.Equal(t, want, s.Graph(GraphDOT))
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		s := Echo("a\nb").Grep(regexp.MustCompile("a"))
+		require.NoError(t, s.Discard())
+
+		durations := regexp.MustCompile(`, [0-9.]+[µnm]?s`)
+		graph := durations.ReplaceAllString(s.Graph(GraphText), ", <duration>")
+		assert.Equal(t, "echo (4 B, 2 lines, <duration>)\n  grep(a, invert=false) (2 B, 1 lines, <duration>)\n", graph)
+	})
+
+	t.Run("exec", func(t *testing.T) {
+		s := Exec("echo", "a").Head(1)
+		assert.Equal(t, "exec(echo, [a])\n  head(1)\n", s.Graph(GraphText))
+		require.NoError(t, s.Discard())
+	})
+
+	t.Run("split", func(t *testing.T) {
+		stdout, stderr := Echo("a").ExecSplit("cat")
+		defer stdout.Close()
+		defer stderr.Close()
+		assert.Equal(t, "echo\n  exec(cat, [])\n    stderr: stderr(exec(cat, []))\n", stderr.Graph(GraphText))
+	})
+}
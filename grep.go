@@ -35,6 +35,19 @@ func (g Grep) Modify(line []byte) (modifed []byte, err error) {
 	return nil, nil
 }
 
+// Shell returns the `grep -E` command of the modifier. The regular expression uses the Go syntax,
+// and if it is not also a valid POSIX extended regular expression, for example if it contains `\d`
+// or `(?i)`, there is no equivalent command.
+func (g Grep) Shell() string {
+	if _, err := regexp.CompilePOSIX(g.Re.String()); err != nil {
+		return ""
+	}
+	if g.Inverse {
+		return "grep -v -E " + Quote(g.Re.String())
+	}
+	return "grep -E " + Quote(g.Re.String())
+}
+
 func (g Grep) Name() string {
 	return fmt.Sprintf("grep(%v, invert=%v)", g.Re, g.Inverse)
 }
@@ -11,10 +11,7 @@ import (
 //
 // Shell command: `head -n <n>`
 func (s Stream) Head(n int) Stream {
-	if n < 0 {
-		return s.Modify(&negHead{n: -n, lines: make([][]byte, 0, -n)})
-	}
-	return s.Modify(&head{n: n})
+	return s.Modify(headModifier(n))
 }
 
 // Tail reads only the n last lines of the given reader. If n is a negative number, all lines
@@ -22,10 +19,21 @@ func (s Stream) Head(n int) Stream {
 //
 // Shell command: `tail -n <n>`
 func (s Stream) Tail(n int) Stream {
+	return s.Modify(tailModifier(n))
+}
+
+func headModifier(n int) Modifier {
+	if n < 0 {
+		return &negHead{n: -n, lines: make([][]byte, 0, -n)}
+	}
+	return &head{n: n}
+}
+
+func tailModifier(n int) Modifier {
 	if n < 0 {
-		return s.Modify(&negTail{n: -n})
+		return &negTail{n: -n}
 	}
-	return s.Modify(&tail{n: n, lines: make([][]byte, 0, n)})
+	return &tail{n: n, lines: make([][]byte, 0, n)}
 }
 
 type head struct {
@@ -40,6 +48,8 @@ func (h *head) Modify(line []byte) ([]byte, error) {
 	return append(line, '\n'), nil
 }
 
+func (h *head) Shell() string { return fmt.Sprintf("head -n %d", h.n) }
+
 func (h *head) Name() string {
 	return fmt.Sprintf("head(%d)", h.n)
 }
@@ -72,6 +82,8 @@ func (h *negHead) Modify(line []byte) ([]byte, error) {
 	return append(ret, byte('\n')), nil
 }
 
+func (h *negHead) Shell() string { return fmt.Sprintf("head -n -%d", h.n) }
+
 func (h *negHead) Name() string {
 	return fmt.Sprintf("head(-%d)", h.n)
 }
@@ -102,6 +114,8 @@ func (t *tail) Modify(line []byte) ([]byte, error) {
 	return nil, nil
 }
 
+func (t *tail) Shell() string { return fmt.Sprintf("tail -n %d", t.n) }
+
 func (t *tail) Name() string {
 	return fmt.Sprintf("tail(%d)", t.n)
 }
@@ -121,6 +135,8 @@ func (t *negTail) Modify(line []byte) ([]byte, error) {
 	return append(line, '\n'), nil
 }
 
+func (t *negTail) Shell() string { return fmt.Sprintf("tail -n +%d", t.n+1) }
+
 func (t *negTail) Name() string {
 	return fmt.Sprintf("tail(-%d)", t.n)
 }
@@ -1,11 +1,13 @@
 package script
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // Files is a stream of a list of files. A user can either use the file list directly or the the
@@ -48,6 +50,8 @@ func Ls(paths ...string) Files {
 	var (
 		files []FileInfo
 		merr  error
+		// shell are the arguments of the equivalent shell command.
+		shell = []string{"ls", "-1d"}
 	)
 
 	for _, path := range paths {
@@ -60,8 +64,10 @@ func Ls(paths ...string) Files {
 		// Path is a single file.
 		if !info.IsDir() {
 			files = append(files, FileInfo{Path: path, FileInfo: info})
+			shell = append(shell, Quote(path))
 			continue
 		}
+		shell = append(shell, Quote(path)+"/*")
 
 		// Path is a directory.
 		dirEntries, err := os.ReadDir(path)
@@ -82,13 +88,22 @@ func Ls(paths ...string) Files {
 	return Files{
 		Stream: Stream{
 			stage: fmt.Sprintf("ls (%+v)", paths),
+			shell: strings.Join(shell, " "),
 			r:     &filesReader{files: files},
 			err:   merr,
-		},
+		}.measure(map[string]string{"paths": quoteAll(paths)}),
 		Files: files,
 	}
 }
 
+// LsContext returns a stream of a list files, like `Ls`. Reading the stream stops once the given
+// context is done. The returned stream is bound to the context.
+func LsContext(ctx context.Context, paths ...string) Files {
+	files := Ls(paths...)
+	files.Stream = files.Stream.WithContext(ctx)
+	return files
+}
+
 // filesReader reads from a file info list.
 type filesReader struct {
 	files []FileInfo
@@ -2,6 +2,7 @@ package script
 
 import (
 	"bufio"
+	"context"
 	"io"
 	"reflect"
 )
@@ -36,6 +37,12 @@ func (s Stream) Modify(modifier Modifier) Stream {
 	return s.Through(modPipe{Modifier: modifier})
 }
 
+// ModifierPipe returns a pipe that applies a modifier on every line of its input. It can be used
+// where a `Pipe` is needed, for example in a `Builtin`.
+func ModifierPipe(modifier Modifier) Pipe {
+	return modPipe{Modifier: modifier}
+}
+
 // modPipe takes a Modifier and exposes the Pipe interface.
 type modPipe struct {
 	Modifier
@@ -43,6 +50,21 @@ type modPipe struct {
 	// partialOut stores leftover of a line that was not fully read by output.
 	partialOut []byte
 	err        error
+	// ctx, when not nil, stops the reading once it is done.
+	ctx context.Context
+}
+
+// Shell returns the shell command of the modifier, if it implements `Sheller`.
+func (m modPipe) Shell() string {
+	if sheller, ok := m.Modifier.(Sheller); ok {
+		return sheller.Shell()
+	}
+	return ""
+}
+
+func (m modPipe) withContext(ctx context.Context) Pipe {
+	m.ctx = ctx
+	return m
 }
 
 func (m modPipe) Pipe(stdin io.Reader) (io.Reader, error) {
@@ -70,6 +92,11 @@ func (m *modPipe) Read(out []byte) (n int, err error) {
 	var partialIn []byte
 
 	for {
+		if m.ctx != nil {
+			if m.ctx.Err() != nil {
+				return 0, context.Cause(m.ctx)
+			}
+		}
 		line, isPrefix, err := m.r.ReadLine()
 		if err != nil {
 			if err != io.EOF {
@@ -0,0 +1,554 @@
+package script
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Parse parses a shell pipeline and returns an equivalent stream. For example:
+//
+//	Parse("cat a.txt | grep -v foo | sort -r | head -n 5 > out.txt")
+//
+// Commands that have an equivalent in this library are implemented by it: `cat`, `echo`, `grep`,
+// `sort`, `head`, `tail`, `cut`, `uniq` and `wc`. Other commands, or commands with flags
+// that are not supported by the library, are executed with `Exec`. Regular expressions of `grep`
+// use the Go syntax.
+//
+// Words can be quoted with single or double quotes, or escaped with a backslash. The redirections
+// `< path` of the first command, and `> path` or `>> path` of the last command, are supported. Other
+// shell features, such as variables expansion, globs, `;` or `&&`, are not supported.
+//
+// The returned stream has no stdin, and a stream of a pipeline that ends with an output
+// redirection has no output. The file of the output redirection is written when the stream is
+// read, for example with `Discard`.
+func Parse(pipeline string) (Stream, error) {
+	p, err := parsePipeline(pipeline)
+	if err != nil {
+		return Stream{}, err
+	}
+	return p.stream(), nil
+}
+
+// ParseGo parses a shell pipeline, like `Parse`, and returns Go code that is equivalent to it. The
+// code is an expression which runs the pipeline, writes its output to the stdout or to the
+// redirected file, and evaluates to an error. For example:
+//
+//	script.Cat("a.txt").Grep(regexp.MustCompile("foo")).ToStdout()
+//
+// Types and functions of this package are referenced with the `script.` prefix, and the code may
+// also reference the `regexp` and `strings` packages.
+func ParseGo(pipeline string) (string, error) {
+	p, err := parsePipeline(pipeline)
+	if err != nil {
+		return "", err
+	}
+	return p.code(), nil
+}
+
+// pipeline is a parsed shell pipeline.
+type pipeline struct {
+	// commands are the arguments of each command in the pipeline, including the command name.
+	commands [][]string
+	// in is the path of the input redirection of the pipeline, if any.
+	in string
+	// out is the path of the output redirection of the pipeline, if any.
+	out string
+	// append is set when the output redirection appends to the file.
+	append bool
+}
+
+func parsePipeline(line string) (*pipeline, error) {
+	tokens, err := tokenize(line)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &pipeline{}
+	var cmd []string
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch tok.op {
+		case "":
+			cmd = append(cmd, tok.word)
+		case "|":
+			if len(cmd) == 0 {
+				return nil, errors.New("empty command in pipeline")
+			}
+			if p.out != "" {
+				return nil, errors.New("output redirection is only allowed in the last command")
+			}
+			p.commands = append(p.commands, cmd)
+			cmd = nil
+		case "<", ">", ">>":
+			if i+1 >= len(tokens) || tokens[i+1].op != "" {
+				return nil, fmt.Errorf("missing path for redirection %s", tok.op)
+			}
+			i++
+			path := tokens[i].word
+			if tok.op == "<" {
+				if len(p.commands) > 0 {
+					return nil, errors.New("input redirection is only allowed in the first command")
+				}
+				p.in = path
+			} else {
+				p.out, p.append = path, tok.op == ">>"
+			}
+		}
+	}
+	if len(cmd) == 0 {
+		return nil, errors.New("empty command in pipeline")
+	}
+	p.commands = append(p.commands, cmd)
+	return p, nil
+}
+
+func (p *pipeline) stream() Stream {
+	var s Stream
+	for _, step := range p.steps() {
+		s = step.apply(s)
+	}
+	if p.out != "" {
+		s = s.Through(fileSink{path: p.out, append: p.append})
+	}
+	return s
+}
+
+// code returns Go code that runs the pipeline.
+func (p *pipeline) code() string {
+	var code strings.Builder
+	for _, step := range p.steps() {
+		code.WriteString(step.code)
+	}
+	switch {
+	case p.out == "":
+		code.WriteString(".ToStdout()")
+	case p.append:
+		fmt.Fprintf(&code, ".AppendFile(%q)", p.out)
+	default:
+		fmt.Fprintf(&code, ".ToFile(%q)", p.out)
+	}
+	return code.String()
+}
+
+// step is a step of building a stream from a parsed pipeline.
+type step struct {
+	// apply applies the step on the stream that was built by the previous steps. The first step
+	// gets an empty stream.
+	apply func(Stream) Stream
+	// code is the Go code of the step. For the first step, it is an expression of a stream, and for
+	// the following steps it is a method call on the stream, for example `.Sort(true)`.
+	code string
+}
+
+// steps returns the steps that build the stream of the pipeline.
+func (p *pipeline) steps() []step {
+	var steps []step
+	for i, cmd := range p.commands {
+		if i == 0 {
+			steps = append(steps, p.source(cmd)...)
+		} else {
+			steps = append(steps, commandStep(cmd))
+		}
+	}
+	return steps
+}
+
+// source returns the steps of the first command in the pipeline.
+func (p *pipeline) source(cmd []string) []step {
+	name, args := cmd[0], cmd[1:]
+	switch {
+	case p.in != "":
+		return []step{
+			{
+				apply: func(Stream) Stream { return Cat(p.in) },
+				code:  fmt.Sprintf("script.Cat(%q)", p.in),
+			},
+			commandStep(cmd),
+		}
+	case name == "cat" && !hasFlags(args):
+		return []step{{
+			apply: func(Stream) Stream { return Cat(args...) },
+			code:  fmt.Sprintf("script.Cat(%s)", goStrings(args)),
+		}}
+	case name == "echo" && !hasFlags(args):
+		return []step{{
+			apply: func(Stream) Stream { return Echo(strings.Join(args, " ")) },
+			code:  fmt.Sprintf("script.Echo(%q)", strings.Join(args, " ")),
+		}}
+	}
+	if native, ok := nativeStep(name, args); ok {
+		return []step{
+			{
+				apply: func(Stream) Stream { return From("empty", nil) },
+				code:  `script.From("empty", nil)`,
+			},
+			native,
+		}
+	}
+	return []step{{
+		apply: func(Stream) Stream { return Exec(name, args...) },
+		code:  fmt.Sprintf("script.Exec(%s)", goStrings(cmd)),
+	}}
+}
+
+// commandStep returns the step of a command that gets the output of the previous command.
+func commandStep(cmd []string) step {
+	if native, ok := nativeStep(cmd[0], cmd[1:]); ok {
+		return native
+	}
+	return step{
+		apply: func(s Stream) Stream { return s.Exec(cmd[0], cmd[1:]...) },
+		code:  fmt.Sprintf(".Exec(%s)", goStrings(cmd)),
+	}
+}
+
+// nativeStep returns the step of a command that is implemented by this library. It returns false if
+// the command or its arguments are not supported.
+func nativeStep(name string, args []string) (step, bool) {
+	switch {
+	case name == "cat" && len(args) == 0:
+		return step{apply: func(s Stream) Stream { return s }}, true
+	case name == "sort" && (len(args) == 0 || len(args) == 1 && args[0] == "-r"):
+		reverse := len(args) == 1
+		return step{
+			apply: func(s Stream) Stream { return s.Sort(reverse) },
+			code:  fmt.Sprintf(".Sort(%v)", reverse),
+		}, true
+	case name == "wc" && len(args) == 0:
+		return step{
+			apply: func(s Stream) Stream { return s.Wc().Stream },
+			code:  ".Wc().Stream",
+		}, true
+	}
+	if m, code, ok := parseModifier(name, args); ok {
+		return step{
+			apply: func(s Stream) Stream { return s.Modify(m) },
+			code:  code,
+		}, true
+	}
+	return step{}, false
+}
+
+// parseModifier returns the modifier of a command and the Go code of adding it to a stream, if the
+// command and its arguments are supported.
+func parseModifier(name string, args []string) (m Modifier, code string, ok bool) {
+	flags, args, ok := parseFlags(args, "n", "d", "f", "e")
+	if !ok {
+		return nil, "", false
+	}
+	switch name {
+	case "grep":
+		pattern, hasPattern := flags["e"]
+		if !hasPattern && len(args) > 0 {
+			pattern, args = args[0], args[1:]
+			hasPattern = true
+		}
+		if !hasPattern || len(args) > 0 || !onlyFlags(flags, "v", "E", "e") {
+			return nil, "", false
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, "", false
+		}
+		if _, inverse := flags["v"]; inverse {
+			return Grep{Re: re, Inverse: true}, fmt.Sprintf(".Modify(script.Grep{Re: regexp.MustCompile(%q), Inverse: true})", pattern), true
+		}
+		return Grep{Re: re}, fmt.Sprintf(".Grep(regexp.MustCompile(%q))", pattern), true
+	case "head", "tail":
+		if len(args) > 0 || !onlyFlags(flags, "n") {
+			return nil, "", false
+		}
+		v, ok := flags["n"]
+		if !ok {
+			v = "10"
+		}
+		if name == "head" {
+			// `head -n -<n>` outputs all the lines but the last n.
+			n, err := strconv.Atoi(strings.TrimPrefix(v, "+"))
+			if err != nil {
+				return nil, "", false
+			}
+			return headModifier(n), fmt.Sprintf(".Head(%d)", n), true
+		}
+		// `tail -n -<n>` is the same as `tail -n <n>`, and `tail -n +<n>` outputs the lines from
+		// line n.
+		n, err := strconv.Atoi(strings.TrimLeft(v, "+-"))
+		if err != nil || n < 0 {
+			return nil, "", false
+		}
+		if strings.HasPrefix(v, "+") {
+			if n <= 1 {
+				return nil, "", false
+			}
+			n = -(n - 1)
+		}
+		return tailModifier(n), fmt.Sprintf(".Tail(%d)", n), true
+	case "cut":
+		if len(args) > 0 || !onlyFlags(flags, "d", "f") {
+			return nil, "", false
+		}
+		var c Cut
+		if d, ok := flags["d"]; ok {
+			if len(d) != 1 {
+				return nil, "", false
+			}
+			c.Delim = []byte(d)
+		}
+		var fields []string
+		for _, f := range strings.Split(flags["f"], ",") {
+			i, err := strconv.Atoi(f)
+			if err != nil || i < 1 {
+				return nil, "", false
+			}
+			c.Fields = append(c.Fields, i)
+			fields = append(fields, f)
+		}
+		if c.Delim == nil {
+			return c, fmt.Sprintf(".Cut(%s)", strings.Join(fields, ", ")), true
+		}
+		return c, fmt.Sprintf(".Modify(script.Cut{Fields: []int{%s}, Delim: []byte(%q)})", strings.Join(fields, ", "), c.Delim), true
+	case "uniq":
+		if len(args) > 0 || !onlyFlags(flags, "c") {
+			return nil, "", false
+		}
+		if _, count := flags["c"]; count {
+			return &Uniq{WriteCount: true}, ".Modify(&script.Uniq{WriteCount: true})", true
+		}
+		return &Uniq{}, ".Uniq()", true
+	}
+	return nil, "", false
+}
+
+// goStrings returns Go code of a list of strings, as function arguments.
+func goStrings(list []string) string {
+	quoted := make([]string, len(list))
+	for i, s := range list {
+		quoted[i] = strconv.Quote(s)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// parseFlags parses short command line flags. The valued flags are followed by a value, either in
+// the same argument or in the next one. A numeric flag, such as `-5`, is parsed as
+// `-n 5`. It returns the parsed flags, the positional arguments, and false if the flags could not
+// be parsed, or if a valued flag is repeated, such as in `grep -e a -e b`.
+func parseFlags(args []string, valued ...string) (map[string]string, []string, bool) {
+	flags := map[string]string{}
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		arg := args[0][1:]
+		args = args[1:]
+		if arg == "-" {
+			break
+		}
+		if _, err := strconv.Atoi(arg); err == nil {
+			if _, ok := flags["n"]; ok {
+				return nil, nil, false
+			}
+			flags["n"] = arg
+			continue
+		}
+		for j := 0; j < len(arg); j++ {
+			flag := arg[j : j+1]
+			if !contains(valued, flag) {
+				flags[flag] = ""
+				continue
+			}
+			if _, ok := flags[flag]; ok {
+				return nil, nil, false
+			}
+			if value := arg[j+1:]; value != "" {
+				flags[flag] = value
+			} else if len(args) > 0 {
+				flags[flag], args = args[0], args[1:]
+			} else {
+				return nil, nil, false
+			}
+			break
+		}
+	}
+	return flags, args, true
+}
+
+// onlyFlags returns true if all the flags are in the allowed list.
+func onlyFlags(flags map[string]string, allowed ...string) bool {
+	for flag := range flags {
+		if !contains(allowed, flag) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasFlags(args []string) bool {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// token is a word or an operator of a shell pipeline.
+type token struct {
+	word string
+	// op is the operator of the token, or empty for a word.
+	op string
+}
+
+// tokenize splits a shell pipeline to words and operators.
+func tokenize(line string) ([]token, error) {
+	var (
+		tokens []token
+		word   strings.Builder
+		// inWord is set when a word was started, it may still be empty, as in `''`.
+		inWord bool
+	)
+	endWord := func() {
+		if inWord {
+			tokens = append(tokens, token{word: word.String()})
+		}
+		word.Reset()
+		inWord = false
+	}
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch c {
+		case ' ', '\t', '\n':
+			endWord()
+		case '|', '<', '>':
+			endWord()
+			op := string(c)
+			if c == '>' && i+1 < len(line) && line[i+1] == '>' {
+				op = ">>"
+				i++
+			}
+			tokens = append(tokens, token{op: op})
+		case ';', '&', '(', ')', '`', '$', '*', '?':
+			return nil, fmt.Errorf("unsupported shell syntax %q at position %d", c, i)
+		case '\\':
+			if i+1 >= len(line) {
+				return nil, errors.New("trailing backslash")
+			}
+			i++
+			word.WriteByte(line[i])
+			inWord = true
+		case '\'':
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			word.WriteString(line[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case '"':
+			i++
+			for ; i < len(line) && line[i] != '"'; i++ {
+				if line[i] == '$' || line[i] == '`' {
+					return nil, fmt.Errorf("unsupported shell syntax %q at position %d", line[i], i)
+				}
+				if line[i] == '\\' && i+1 < len(line) && strings.IndexByte("\"\\$`", line[i+1]) >= 0 {
+					i++
+				}
+				word.WriteByte(line[i])
+			}
+			if i >= len(line) {
+				return nil, errors.New("unterminated double quote")
+			}
+			inWord = true
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	endWord()
+	return tokens, nil
+}
+
+// fileSink is a pipe that writes its input to a file and has no output.
+//
+// Shell command: `> <path>` or `>> <path>`.
+type fileSink struct {
+	path   string
+	append bool
+	// dryRun, when not nil, reports the file to it instead of writing it.
+	dryRun io.Writer
+}
+
+func (f fileSink) Name() string {
+	if f.append {
+		return fmt.Sprintf("append-file(%s)", f.path)
+	}
+	return fmt.Sprintf("to-file(%s)", f.path)
+}
+
+func (f fileSink) Shell() string {
+	if f.append {
+		return "cat >> " + Quote(f.path)
+	}
+	return "cat > " + Quote(f.path)
+}
+
+func (f fileSink) withDryRun(w io.Writer) Pipe {
+	f.dryRun = w
+	return f
+}
+
+// Pipe returns a reader that opens the file on its first read, such that the file is not changed
+// until the stream is read.
+func (f fileSink) Pipe(stdin io.Reader) (io.Reader, error) {
+	return &lazyReader{create: func() (io.Reader, error) {
+		if f.dryRun != nil {
+			reportFile(f.dryRun, f.path, f.append)
+			return &sinkReader{r: stdin, w: nopWriteCloser{io.Discard}}, nil
+		}
+		open := File
+		if f.append {
+			open = AppendFile
+		}
+		w, err := open(f.path)
+		if err != nil {
+			return strings.NewReader(""), err
+		}
+		return &sinkReader{r: stdin, w: w}, nil
+	}}, nil
+}
+
+// sinkReader copies its input to a writer on the first read, and has no output.
+type sinkReader struct {
+	r io.Reader
+	w io.WriteCloser
+}
+
+func (s *sinkReader) Read([]byte) (int, error) {
+	if s.r == nil {
+		return 0, io.EOF
+	}
+	_, err := io.Copy(s.w, s.r)
+	s.r = nil
+	if err != nil {
+		return 0, err
+	}
+	return 0, io.EOF
+}
+
+func (s *sinkReader) Close() error {
+	return s.w.Close()
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
@@ -0,0 +1,151 @@
+package script
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pipeline string
+		want     string
+	}{
+		{pipeline: "cat testdata/a.txt testdata/b.txt", want: "a\nbb\n"},
+		{pipeline: "cat testdata/a.txt testdata/b.txt | sort -r | head -n 1", want: "bb\n"},
+		{pipeline: "echo 'a b'   \"c\\\"d\" e\\ f | cut -d ' ' -f 2,3", want: "b c\"d\n"},
+		{pipeline: "echo a | grep -v a", want: ""},
+		{pipeline: "echo ab | grep -e '^a'", want: "ab\n"},
+		{pipeline: "grep b < testdata/b.txt", want: "bb\n"},
+		{pipeline: "<testdata/b.txt cat", want: "bb\n"},
+		{pipeline: "ls testdata | tail -1", want: "b.txt\n"},
+		{pipeline: "cat testdata/a.txt testdata/a.txt | uniq -c", want: "2\ta\n"},
+		{pipeline: "cat testdata/b.txt | wc", want: "1\t1\t3\n"},
+		{pipeline: "grep a", want: ""},
+		{pipeline: "echo 'a\nb\nc\nd\ne\nf' | tail -n +5", want: "e\nf\n"},
+		{pipeline: "echo 'a\nb\nc' | tail -n -2", want: "b\nc\n"},
+		{pipeline: "echo 'a\nb\nc' | tail -n +1", want: "a\nb\nc\n"},
+		{pipeline: "echo 'a\nb\nc' | head -n -1", want: "a\nb\n"},
+		// Not implemented by the library.
+		{pipeline: "echo hello | tr a-z A-Z", want: "HELLO\n"},
+		{pipeline: "echo -n hello", want: "hello"},
+		{pipeline: "cat testdata/a.txt | grep -c a", want: "1\n"},
+		{pipeline: "cat testdata/a.txt testdata/b.txt | grep -e a -e b", want: "a\nbb\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pipeline, func(t *testing.T) {
+			s, err := Parse(tt.pipeline)
+			require.NoError(t, err)
+			got, err := s.ToString()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParse_redirection(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "out.txt")
+
+	s, err := Parse("cat testdata/a.txt > " + path)
+	require.NoError(t, err)
+	got, err := s.ToString()
+	require.NoError(t, err)
+	assert.Equal(t, "", got)
+
+	s, err = Parse("cat testdata/b.txt >>" + path)
+	require.NoError(t, err)
+	require.NoError(t, s.Discard())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "a\nbb\n", string(content))
+
+	// The file is not changed until the stream is read.
+	s, err = Parse("echo new > " + path)
+	require.NoError(t, err)
+	content, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "a\nbb\n", string(content))
+	require.NoError(t, s.Discard())
+	content, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "new\n", string(content))
+}
+
+func TestParse_errors(t *testing.T) {
+	t.Parallel()
+
+	for _, pipeline := range []string{
+		"",
+		"cat a |",
+		"| cat a",
+		"cat a | | cat b",
+		"cat 'a",
+		"cat \"a",
+		"cat a\\",
+		"cat a >",
+		"cat a > b | cat",
+		"cat | cat < a",
+		"cat a; cat b",
+		"cat $HOME",
+		"echo \"$HOME\"",
+	} {
+		t.Run(pipeline, func(t *testing.T) {
+			_, err := Parse(pipeline)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParseGo(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pipeline string
+		want     string
+	}{
+		{
+			pipeline: "cat a.txt b.txt | sort -r | head -n 1",
+			want:     `script.Cat("a.txt", "b.txt").Sort(true).Head(1).ToStdout()`,
+		},
+		{
+			pipeline: "echo a b | grep -v 'a\"' | cut -d ' ' -f 1,2 | uniq -c | wc > out.txt",
+			want:     `script.Echo("a b").Modify(script.Grep{Re: regexp.MustCompile("a\""), Inverse: true}).Modify(script.Cut{Fields: []int{1, 2}, Delim: []byte(" ")}).Modify(&script.Uniq{WriteCount: true}).Wc().Stream.ToFile("out.txt")`,
+		},
+		{
+			pipeline: "grep a < in.txt | cut -f 2 | tr a b | cat | uniq >> out.txt",
+			want:     `script.Cat("in.txt").Grep(regexp.MustCompile("a")).Cut(2).Exec("tr", "a", "b").Uniq().AppendFile("out.txt")`,
+		},
+		{
+			pipeline: "tail -3",
+			want:     `script.From("empty", nil).Tail(3).ToStdout()`,
+		},
+		{
+			pipeline: "grep -e a -e b",
+			want:     `script.Exec("grep", "-e", "a", "-e", "b").ToStdout()`,
+		},
+		{
+			pipeline: "ls -l | sort",
+			want:     `script.Exec("ls", "-l").Sort(false).ToStdout()`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pipeline, func(t *testing.T) {
+			got, err := ParseGo(tt.pipeline)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := ParseGo("cat |")
+	assert.Error(t, err)
+}
@@ -0,0 +1,153 @@
+package script
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ProgressOptions are options for reporting the progress of a stream.
+type ProgressOptions struct {
+	// Interval is the time between reports. The default is one second.
+	Interval time.Duration
+	// Total is the expected number of bytes from the source of the stream. If zero, and the source
+	// is `Cat` of regular files, the total size of the files is used.
+	Total int64
+}
+
+// Progress passes the stream as is, and periodically reports to w the bytes and lines that passed
+// through it and the throughput. When the total size of the source of the stream is known, the
+// report includes the percentage of the source that was read, and the estimated time until it is
+// done. A final report is written when the stream is done or closed.
+//
+//	err := script.Cat("access.log").Progress(os.Stderr, script.ProgressOptions{}).Grep(re).ToFile("out.log")
+//
+// Shell command: `cat` (the progress is not reported).
+func (s Stream) Progress(w io.Writer, opts ProgressOptions) Stream {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+	// The source is the first stage that has a reader, a stream without a reader, such as the
+	// source of `Exec`, has no output.
+	var source *Stream
+	for cur := &s; cur != nil; cur = cur.parent {
+		if cur.r != nil {
+			source = cur
+		}
+	}
+	p := progress{w: w, opts: opts}
+	if source != nil {
+		if p.opts.Total == 0 {
+			p.opts.Total = source.size
+		}
+		p.source = source.stats
+	}
+	return s.Through(p)
+}
+
+type progress struct {
+	w    io.Writer
+	opts ProgressOptions
+	// source measures the source of the stream.
+	source *stageStats
+}
+
+func (progress) Name() string { return "progress" }
+
+func (progress) Shell() string { return "cat" }
+
+func (p progress) Pipe(stdin io.Reader) (io.Reader, error) {
+	return &progressReader{progress: p, r: stdin, stop: make(chan struct{})}, nil
+}
+
+// progressReader counts the data that is read through it, and reports it.
+type progressReader struct {
+	progress
+	r     io.Reader
+	start time.Time
+	bytes atomic.Int64
+	lines atomic.Int64
+
+	started, stopped sync.Once
+	stop             chan struct{}
+	// mu protects writing the reports.
+	mu sync.Mutex
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	p.started.Do(func() {
+		p.start = time.Now()
+		go p.run()
+	})
+	n, err := p.r.Read(b)
+	p.bytes.Add(int64(n))
+	p.lines.Add(int64(bytes.Count(b[:n], []byte{'\n'})))
+	if err != nil {
+		p.finish()
+	}
+	return n, err
+}
+
+func (p *progressReader) Close() error {
+	p.started.Do(func() { p.start = time.Now() })
+	p.finish()
+	return nil
+}
+
+// run reports the progress periodically until it is stopped.
+func (p *progressReader) run() {
+	ticker := time.NewTicker(p.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.report()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// finish stops the periodic reports and writes the final report.
+func (p *progressReader) finish() {
+	p.stopped.Do(func() {
+		close(p.stop)
+		p.report()
+	})
+}
+
+func (p *progressReader) report() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	elapsed := time.Since(p.start)
+	n := p.bytes.Load()
+	msg := fmt.Sprintf("progress: %s, %d lines", formatBytes(float64(n)), p.lines.Load())
+	if elapsed > 0 {
+		msg += fmt.Sprintf(", %s/s", formatBytes(float64(n)/elapsed.Seconds()))
+	}
+	if p.opts.Total > 0 && p.source != nil {
+		read := p.source.get("").BytesOut
+		msg += fmt.Sprintf(", %d%%", read*100/p.opts.Total)
+		if read > 0 {
+			eta := time.Duration(float64(elapsed) * float64(p.opts.Total-read) / float64(read))
+			msg += fmt.Sprintf(", ETA %s", eta.Round(time.Second))
+		}
+	}
+	fmt.Fprintln(p.w, msg)
+}
+
+// formatBytes formats a number of bytes with a binary unit.
+func formatBytes(n float64) string {
+	const units = "KMGTPE"
+	if n < 1024 {
+		return fmt.Sprintf("%.0f B", n)
+	}
+	i := -1
+	for ; n >= 1024 && i < len(units)-1; i++ {
+		n /= 1024
+	}
+	return fmt.Sprintf("%.1f %ciB", n, units[i])
+}
@@ -0,0 +1,77 @@
+package script
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgress(t *testing.T) {
+	t.Parallel()
+
+	t.Run("cat", func(t *testing.T) {
+		var report bytes.Buffer
+		out, err := Cat("testdata/a.txt", "testdata/b.txt").Progress(&report, ProgressOptions{}).ToString()
+		require.NoError(t, err)
+		assert.Equal(t, "a\nbb\n", out)
+
+		assert.Regexp(t, `^progress: 5 B, 2 lines, \S+ \S*B/s, 100%, ETA 0s\n$`, report.String())
+	})
+
+	t.Run("exec", func(t *testing.T) {
+		var report bytes.Buffer
+		err := Exec("seq", "1", "1000").Progress(&report, ProgressOptions{Total: 3893}).Discard()
+		require.NoError(t, err)
+
+		assert.Regexp(t, `^progress: 3\.8 KiB, 1000 lines, \S+ \S*B/s, 100%, ETA 0s\n$`, report.String())
+	})
+
+	t.Run("periodic", func(t *testing.T) {
+		var report syncBuffer
+		r, w := io.Pipe()
+		s := From("pipe", r).Progress(&report, ProgressOptions{Interval: time.Millisecond, Total: 4096})
+		go func() {
+			w.Write(bytes.Repeat([]byte("x\n"), 1024))
+			time.Sleep(20 * time.Millisecond)
+			w.Close()
+		}()
+		require.NoError(t, s.Discard())
+
+		lines := strings.Split(strings.TrimSpace(report.String()), "\n")
+		require.Greater(t, len(lines), 1)
+		assert.Regexp(t, `^progress: 2\.0 KiB, 1024 lines, \S+ \S*B/s, 50%, ETA \S+$`, lines[len(lines)-1])
+	})
+}
+
+func TestFormatBytes(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "0 B", formatBytes(0))
+	assert.Equal(t, "1023 B", formatBytes(1023))
+	assert.Equal(t, "1.5 KiB", formatBytes(1536))
+	assert.Equal(t, "3.0 GiB", formatBytes(3<<30))
+}
+
+// syncBuffer is a buffer that can be written and read concurrently.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,81 @@
+package script
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Sh runs a shell script with `/bin/sh -c` and returns a stream of its stdout.
+//
+// Values that are interpolated into the script should be quoted with `Quote`, or the script
+// should be created with `Shf`.
+//
+// Shell command: `sh -c <script>`.
+func Sh(script string) Stream {
+	return Exec("/bin/sh", "-c", script)
+}
+
+// Shf runs a shell script that is created from a format and arguments, like `fmt.Sprintf`. Each
+// argument is formatted with `fmt.Sprint` and quoted with `Quote`, so it is interpreted by the
+// shell as a single word. The arguments should be referenced in the format with the `%s` verb.
+//
+// For example, the following lists a file that contains spaces in its name:
+//
+//	Shf("ls -l %s | wc -l", "my file.txt")
+func Shf(format string, args ...interface{}) Stream {
+	return Sh(quotef(format, args...))
+}
+
+// Sh runs a shell script with `/bin/sh -c`, with the current stream as its stdin, and returns a
+// stream of its stdout.
+//
+// Shell command: `sh -c <script>`.
+func (s Stream) Sh(script string) Stream {
+	return s.Exec("/bin/sh", "-c", script)
+}
+
+// Shf runs a shell script that is created from a format and quoted arguments, with the current
+// stream as its stdin. See the `Shf` function.
+func (s Stream) Shf(format string, args ...interface{}) Stream {
+	return s.Sh(quotef(format, args...))
+}
+
+// Quote quotes a string such that it is interpreted by a POSIX shell as a single word with the
+// exact value of the string.
+func Quote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	if strings.IndexFunc(s, unsafeShellRune) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// quoteAll quotes each of the given words and joins them with spaces.
+func quoteAll(words []string) string {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = Quote(word)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func quotef(format string, args ...interface{}) string {
+	quoted := make([]interface{}, len(args))
+	for i, arg := range args {
+		quoted[i] = Quote(fmt.Sprint(arg))
+	}
+	return fmt.Sprintf(format, quoted...)
+}
+
+// unsafeShellRune returns true for runes that may have a special meaning in a shell.
+func unsafeShellRune(r rune) bool {
+	switch {
+	case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+		return false
+	case strings.ContainsRune("@%+=:,./-_", r):
+		return false
+	}
+	return true
+}
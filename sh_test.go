@@ -0,0 +1,65 @@
+package script
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSh(t *testing.T) {
+	t.Parallel()
+
+	t.Run("script", func(t *testing.T) {
+		got, err := Sh("echo a b | tr ' ' '\n'").ToString()
+		require.NoError(t, err)
+		assert.Equal(t, "a\nb\n", got)
+	})
+
+	t.Run("with stdin", func(t *testing.T) {
+		got, err := Echo("a\nb").Sh("wc -l | tr -d ' '").ToString()
+		require.NoError(t, err)
+		assert.Equal(t, "2\n", got)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		_, err := Sh("exit 2").ToString()
+		assert.Error(t, err)
+	})
+}
+
+func TestShf(t *testing.T) {
+	t.Parallel()
+
+	for _, arg := range []string{"", "a b", "it's", `"$HOME"`, "`id`; rm -rf x", "a\nb", "\\"} {
+		t.Run(arg, func(t *testing.T) {
+			got, err := Shf("printf '%%s' %s", arg).ToString()
+			require.NoError(t, err)
+			assert.Equal(t, arg, got)
+		})
+	}
+
+	t.Run("non string args", func(t *testing.T) {
+		got, err := Echo("x").Shf("cat; echo %s %s", 1, true).ToString()
+		require.NoError(t, err)
+		assert.Equal(t, "x\n1 true\n", got)
+	})
+}
+
+func TestQuote(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct{ in, want string }{
+		{in: "", want: "''"},
+		{in: "simple/path-1.txt", want: "simple/path-1.txt"},
+		{in: "a b", want: "'a b'"},
+		{in: "it's", want: `'it'\''s'`},
+		{in: "$HOME", want: "'$HOME'"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.in), func(t *testing.T) {
+			assert.Equal(t, tt.want, Quote(tt.in))
+		})
+	}
+}
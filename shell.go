@@ -0,0 +1,37 @@
+package script
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Sheller is implemented by pipes and modifiers that have an equivalent shell command. It is used
+// by the stream `Shell` method.
+type Sheller interface {
+	// Shell returns a POSIX shell command that is equivalent to the pipe or the modifier. It
+	// returns an empty string if there is no equivalent command.
+	Shell() string
+}
+
+// Shell returns a POSIX shell pipeline that is equivalent to the stream. It fails if any of the
+// stages in the stream has no shell equivalent, for example a custom `PipeFn` or `ModifyFn`.
+//
+// Options that don't change the output of the stream, such as timeouts, are not represented in
+// the shell pipeline.
+func (s Stream) Shell() (string, error) {
+	var cmds []string
+	for cur := &s; cur != nil; cur = cur.parent {
+		// A stream without a reader has no input, such as the source of `Exec`.
+		if cur.r == nil {
+			continue
+		}
+		if cur.shell == "" {
+			return "", fmt.Errorf("stage %d (%s) has no shell equivalent", cur.index, cur.stage)
+		}
+		cmds = append(cmds, cur.shell)
+	}
+	for i, j := 0, len(cmds)-1; i < j; i, j = i+1, j-1 {
+		cmds[i], cmds[j] = cmds[j], cmds[i]
+	}
+	return strings.Join(cmds, " | "), nil
+}
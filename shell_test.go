@@ -0,0 +1,95 @@
+package script
+
+import (
+	"io"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShell(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		stream Stream
+		want   string
+	}{
+		{
+			name:   "cat",
+			stream: Cat("testdata/a.txt", "testdata/b.txt").Sort(true).Head(1),
+			want:   "cat testdata/a.txt testdata/b.txt | LC_ALL=C sort -r | head -n 1",
+		},
+		{
+			name:   "echo",
+			stream: Echo("it's\nbb").Grep(regexp.MustCompile("b+")).Modify(Cut{Fields: []int{1}, Delim: []byte{' '}}).Tail(-1),
+			want:   "printf '%s\\n' 'it'\\''s\nbb' | grep -E b+ | cut -f 1 -d ' ' | tail -n +2",
+		},
+		{
+			name:   "exec",
+			stream: Exec("echo", "a b").ExecWith(ExecOptions{Dir: "testdata", Env: []string{"A=1"}, MergeStderr: true}, "cat").Uniq().Wc().Stream,
+			want:   "echo 'a b' | (cd testdata && env -i A=1 cat 2>&1) | uniq | wc",
+		},
+		{
+			name:   "ls",
+			stream: Ls("testdata", "testdata/a.txt").Head(-1).Tail(2),
+			want:   "ls -1d testdata/* testdata/a.txt | head -n -1 | tail -n 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer tt.stream.Close()
+			got, err := tt.stream.Shell()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestShell_equivalent(t *testing.T) {
+	t.Parallel()
+
+	for _, pipeline := range []string{
+		"cat testdata/a.txt testdata/b.txt | sort -r | head -n 1",
+		"ls testdata | grep -v b | uniq",
+		"echo 'a b' | cut -d ' ' -f 2",
+		"cat testdata/b.txt | tr b c",
+		"uniq -c",
+	} {
+		t.Run(pipeline, func(t *testing.T) {
+			s, err := Parse(pipeline)
+			require.NoError(t, err)
+			shell, err := s.Shell()
+			require.NoError(t, err)
+			want, err := s.ToString()
+			require.NoError(t, err)
+
+			got, err := Sh(shell).ToString()
+			require.NoError(t, err)
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func TestShell_noEquivalent(t *testing.T) {
+	t.Parallel()
+
+	s := Echo("a").Through(PipeFn(func(r io.Reader) (io.Reader, error) { return r, nil })).Head(1)
+	defer s.Close()
+	_, err := s.Shell()
+	assert.EqualError(t, err, "stage 1 (PipeFn) has no shell equivalent")
+
+	s = Echo("a").Modify(ModifyFn(func(line []byte) ([]byte, error) { return line, nil }))
+	defer s.Close()
+	_, err = s.Shell()
+	assert.EqualError(t, err, "stage 1 (ModifyFn) has no shell equivalent")
+
+	// Not a POSIX extended regular expression.
+	s = Echo("a1").Grep(regexp.MustCompile(`a\d`))
+	defer s.Close()
+	_, err = s.Shell()
+	assert.EqualError(t, err, "stage 1 (grep(a\\d, invert=false)) has no shell equivalent")
+}
@@ -0,0 +1,138 @@
+package script
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ForwardSignals forwards the given signals, received by the current process, to all the commands
+// that are running in streams in their own process group, see `ExecOptions.ProcessGroup`. If no
+// signals are given, SIGINT and SIGTERM are forwarded. It returns a function that stops the
+// forwarding.
+//
+// Signals that are sent by the terminal to the foreground process group, for example by pressing
+// Ctrl+C, do not reach commands in their own process group unless they are forwarded. Other
+// commands get them from the terminal, and are not forwarded the signals. Notice that while the
+// signals are forwarded, they don't terminate the current process.
+func ForwardSignals(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	c := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(c, sigs...)
+	go func() {
+		for {
+			select {
+			case sig := <-c:
+				running.signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(c)
+			close(done)
+		})
+	}
+}
+
+// running holds the commands that are currently running.
+var running = groups{m: map[*group]bool{}}
+
+type groups struct {
+	mu sync.Mutex
+	m  map[*group]bool
+}
+
+func (gs *groups) add(g *group) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.m[g] = true
+}
+
+func (gs *groups) remove(g *group) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	delete(gs.m, g)
+}
+
+func (gs *groups) signal(sig os.Signal) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	for g := range gs.m {
+		if g.own {
+			g.signal(sig)
+		}
+	}
+}
+
+// group controls the processes of a running command.
+type group struct {
+	cmd *exec.Cmd
+	// own is set when the command runs in its own process group.
+	own bool
+	// grace is the time between terminating the processes and killing them.
+	grace time.Duration
+	// exited is closed once the command was waited for.
+	exited chan struct{}
+	// waited makes the command waited for once, err is the result of the wait.
+	waited sync.Once
+	err    error
+}
+
+// newGroup returns the group of a command that was not started yet.
+func newGroup(cmd *exec.Cmd, grace time.Duration, own bool) *group {
+	return &group{cmd: cmd, own: own, grace: grace, exited: make(chan struct{})}
+}
+
+// signal sends a signal to the process group of the command if it runs in its own group, or to the
+// command otherwise.
+func (g *group) signal(sig os.Signal) error {
+	if g.own {
+		return signalGroup(g.cmd.Process, sig)
+	}
+	return g.cmd.Process.Signal(sig)
+}
+
+// terminate stops the processes of the group. Without a grace period, they are killed. Otherwise,
+// they are sent SIGTERM, and are killed only if the command did not exit within the grace period.
+func (g *group) terminate() error {
+	select {
+	case <-g.exited:
+		// The process was waited for, and its pid may be reused.
+		return nil
+	default:
+	}
+	if g.grace <= 0 {
+		return g.signal(os.Kill)
+	}
+	err := g.signal(syscall.SIGTERM)
+	go func() {
+		select {
+		case <-g.exited:
+		case <-time.After(g.grace):
+			g.signal(os.Kill)
+		}
+	}()
+	return err
+}
+
+// wait waits for the command to exit. It can be called more than once, and returns the result of
+// the first wait.
+func (g *group) wait() error {
+	g.waited.Do(func() {
+		g.err = g.cmd.Wait()
+		running.remove(g)
+		close(g.exited)
+	})
+	return g.err
+}
@@ -2,41 +2,299 @@ package script
 
 import (
 	"bufio"
+	"container/heap"
 	"errors"
 	"fmt"
+	"io"
+	"os"
 	"sort"
 	"strings"
 )
 
-// Sort returns a stream with lines ordered alphabetically.
+// Sort returns a stream with lines ordered alphabetically. The lines are read and sorted on the
+// first read of the stream.
 //
-// Shell command: `wc`.
+// Shell command: `sort`.
 func (s Stream) Sort(reverse bool) Stream {
+	return s.SortWith(SortOptions{Reverse: reverse})
+}
+
+// SortOptions are options for sorting a stream.
+type SortOptions struct {
+	// Reverse orders the lines in reverse order.
+	Reverse bool
+	// MemoryLimit is the approximate number of bytes of lines that are held in memory. When the
+	// input is larger, it is sorted in chunks up to this size that are written to temporary files,
+	// and the files are merged when the stream is read. The temporary files are removed when the
+	// stream is closed. If zero, all the lines are sorted in memory.
+	MemoryLimit int
+	// TempDir is the directory of the temporary files. If empty, the default directory for
+	// temporary files is used.
+	TempDir string
+}
+
+// SortWith returns a stream with lines ordered alphabetically, according to the given options.
+//
+// Shell command: `sort`.
+func (s Stream) SortWith(opts SortOptions) Stream {
+	return s.Through(sortPipe{opts: opts})
+}
+
+// sortPipe is a pipe that outputs the lines of its input ordered alphabetically.
+type sortPipe struct {
+	opts SortOptions
+}
+
+func (p sortPipe) Name() string {
+	return fmt.Sprintf("sort(%v)", p.opts.Reverse)
+}
+
+func (p sortPipe) Shell() string {
+	if p.opts.Reverse {
+		return "LC_ALL=C sort -r"
+	}
+	return "LC_ALL=C sort"
+}
+
+func (p sortPipe) Pipe(stdin io.Reader) (io.Reader, error) {
+	return &lazyReader{create: func() (io.Reader, error) {
+		if p.opts.MemoryLimit > 0 {
+			return p.sortExternal(stdin)
+		}
+		return p.sort(stdin)
+	}}, nil
+}
+
+func (p sortPipe) sort(stdin io.Reader) (io.Reader, error) {
 	var (
 		lines []string
 		merr  error
 	)
-	scanner := bufio.NewScanner(s.r)
+	scanner := bufio.NewScanner(stdin)
 	for scanner.Scan() {
 		lines = append(lines, scanner.Text())
 	}
 	if err := scanner.Err(); err != nil {
 		merr = errors.Join(merr, fmt.Errorf("scanning stream: %w", err))
 	}
+	return strings.NewReader(p.join(p.sortLines(lines))), merr
+}
+
+// sortExternal sorts the input in chunks that are up to the memory limit. If the input is larger
+// than a single chunk, the chunks are written to temporary files and the returned reader merges
+// them.
+func (p sortPipe) sortExternal(stdin io.Reader) (io.Reader, error) {
+	var (
+		lines []string
+		size  int
+		m     = &mergeReader{less: p.less, dir: p.opts.TempDir}
+	)
+	scanner := bufio.NewScanner(stdin)
+	for scanner.Scan() {
+		line := scanner.Text()
+		lines = append(lines, line)
+		size += len(line) + 1
+		if size < p.opts.MemoryLimit {
+			continue
+		}
+		if err := m.spill(p.sortLines(lines)); err != nil {
+			return strings.NewReader(""), errors.Join(err, m.Close())
+		}
+		lines, size = lines[:0], 0
+	}
+	if err := scanner.Err(); err != nil {
+		return strings.NewReader(""), errors.Join(fmt.Errorf("scanning stream: %w", err), m.Close())
+	}
+
+	// The input fits in a single chunk.
+	if len(m.paths) == 0 {
+		return strings.NewReader(p.join(p.sortLines(lines))), nil
+	}
+	if len(lines) > 0 {
+		if err := m.spill(p.sortLines(lines)); err != nil {
+			return strings.NewReader(""), errors.Join(err, m.Close())
+		}
+	}
+	if err := m.start(); err != nil {
+		return strings.NewReader(""), errors.Join(err, m.Close())
+	}
+	return m, nil
+}
+
+func (p sortPipe) less(a, b string) bool {
+	if p.opts.Reverse {
+		return a > b
+	}
+	return a < b
+}
 
-	sort.Slice(lines, func(i, j int) bool { return (lines[i] < lines[j]) != reverse })
+func (p sortPipe) sortLines(lines []string) []string {
+	sort.Slice(lines, func(i, j int) bool { return p.less(lines[i], lines[j]) })
+	return lines
+}
 
+func (p sortPipe) join(lines []string) string {
 	var out strings.Builder
 	for _, line := range lines {
-		_, err := out.WriteString(line + "\n")
+		out.WriteString(line + "\n")
+	}
+	return out.String()
+}
+
+// maxMergeFiles is the maximal number of temporary files that are merged at once.
+const maxMergeFiles = 64
+
+// mergeReader merges sorted temporary files. The files are removed when it is closed.
+type mergeReader struct {
+	less func(a, b string) bool
+	// dir is the directory of the temporary files.
+	dir string
+	// paths are the temporary files.
+	paths []string
+	// files are the open temporary files that are merged.
+	files []*os.File
+	// heads holds the next line of each file that was not fully read.
+	heads mergeHeap
+	// out is the output that was not read yet.
+	out []byte
+	err error
+}
+
+// spill writes sorted lines to a new temporary file.
+func (m *mergeReader) spill(lines []string) error {
+	return m.createTemp(func(w io.Writer) error {
+		for _, line := range lines {
+			if _, err := io.WriteString(w, line+"\n"); err != nil {
+				return fmt.Errorf("write temporary file: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// createTemp creates a new temporary file with the content that is written by write, and closes
+// it.
+func (m *mergeReader) createTemp(write func(w io.Writer) error) error {
+	f, err := os.CreateTemp(m.dir, "script-sort-")
+	if err != nil {
+		return fmt.Errorf("create temporary file: %w", err)
+	}
+	m.paths = append(m.paths, f.Name())
+	w := bufio.NewWriter(f)
+	err = write(w)
+	if err == nil {
+		if err = w.Flush(); err != nil {
+			err = fmt.Errorf("write temporary file: %w", err)
+		}
+	}
+	if cerr := f.Close(); cerr != nil && err == nil {
+		err = fmt.Errorf("write temporary file: %w", cerr)
+	}
+	return err
+}
+
+// start starts merging the files. When there are more than `maxMergeFiles` files, they are merged
+// to larger files first, such that the number of open files is bounded.
+func (m *mergeReader) start() error {
+	for len(m.paths) > maxMergeFiles {
+		pass := &mergeReader{less: m.less, paths: m.paths[:maxMergeFiles:maxMergeFiles]}
+		m.paths = m.paths[maxMergeFiles:]
+		err := pass.open()
+		if err == nil {
+			err = m.createTemp(func(w io.Writer) error {
+				if _, err := io.Copy(w, pass); err != nil {
+					return fmt.Errorf("merge temporary files: %w", err)
+				}
+				return nil
+			})
+		}
+		if err := errors.Join(err, pass.Close()); err != nil {
+			return err
+		}
+	}
+	return m.open()
+}
+
+// open opens the files and starts reading them from their beginning.
+func (m *mergeReader) open() error {
+	m.heads.less = m.less
+	for _, path := range m.paths {
+		f, err := os.Open(path)
 		if err != nil {
-			merr = errors.Join(merr, fmt.Errorf("writing line %q: %w", line, err))
+			return fmt.Errorf("read temporary file: %w", err)
+		}
+		m.files = append(m.files, f)
+		m.push(bufio.NewScanner(f))
+	}
+	return m.err
+}
+
+// push adds the next line of a file to the heads, if it has one.
+func (m *mergeReader) push(scanner *bufio.Scanner) {
+	if scanner.Scan() {
+		heap.Push(&m.heads, mergeHead{line: scanner.Text(), scanner: scanner})
+		return
+	}
+	if err := scanner.Err(); err != nil {
+		m.err = errors.Join(m.err, fmt.Errorf("read temporary file: %w", err))
+	}
+}
+
+func (m *mergeReader) Read(b []byte) (int, error) {
+	for len(m.out) == 0 {
+		if m.err != nil {
+			return 0, m.err
+		}
+		if m.heads.Len() == 0 {
+			return 0, io.EOF
 		}
+		head := heap.Pop(&m.heads).(mergeHead)
+		m.out = append(m.out[:0], head.line...)
+		m.out = append(m.out, '\n')
+		m.push(head.scanner)
 	}
+	n := copy(b, m.out)
+	m.out = m.out[n:]
+	return n, nil
+}
 
-	return Stream{
-		stage: fmt.Sprintf("sort(%v)", reverse),
-		r:     strings.NewReader(out.String()),
-		err:   merr,
+// Close closes and removes the temporary files.
+func (m *mergeReader) Close() error {
+	var merr error
+	for _, f := range m.files {
+		if err := f.Close(); err != nil {
+			merr = errors.Join(merr, err)
+		}
 	}
+	for _, path := range m.paths {
+		if err := os.Remove(path); err != nil {
+			merr = errors.Join(merr, err)
+		}
+	}
+	m.files, m.paths = nil, nil
+	return merr
+}
+
+// mergeHead is the next line of a temporary file.
+type mergeHead struct {
+	line    string
+	scanner *bufio.Scanner
+}
+
+// mergeHeap orders the next lines of temporary files.
+type mergeHeap struct {
+	heads []mergeHead
+	less  func(a, b string) bool
+}
+
+func (h mergeHeap) Len() int           { return len(h.heads) }
+func (h mergeHeap) Less(i, j int) bool { return h.less(h.heads[i].line, h.heads[j].line) }
+func (h mergeHeap) Swap(i, j int)      { h.heads[i], h.heads[j] = h.heads[j], h.heads[i] }
+
+func (h *mergeHeap) Push(x any) { h.heads = append(h.heads, x.(mergeHead)) }
+
+func (h *mergeHeap) Pop() any {
+	last := h.heads[len(h.heads)-1]
+	h.heads = h.heads[:len(h.heads)-1]
+	return last
 }
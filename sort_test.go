@@ -1,6 +1,10 @@
 package script
 
 import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -21,4 +25,98 @@ func TestSort(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, "b\nab\na\n", out)
 	})
+
+	t.Run("lazy", func(t *testing.T) {
+		var read bool
+		s := From("reader", readerFn(func(_ []byte) (int, error) {
+			read = true
+			return 0, io.EOF
+		})).Sort(false)
+		assert.False(t, read)
+
+		require.NoError(t, s.Discard())
+		assert.True(t, read)
+	})
+}
+
+func TestSortWith_memoryLimit(t *testing.T) {
+	t.Parallel()
+
+	var lines []string
+	for i := 0; i < 1000; i++ {
+		lines = append(lines, strconv.Itoa((i*7919)%1000))
+	}
+	input := strings.Join(lines, "\n")
+	want, err := Echo(input).Sort(false).ToString()
+	require.NoError(t, err)
+	wantReversed, err := Echo(input).Sort(true).ToString()
+	require.NoError(t, err)
+
+	t.Run("merge", func(t *testing.T) {
+		dir := t.TempDir()
+		s := Echo(input).SortWith(SortOptions{MemoryLimit: 100, TempDir: dir})
+
+		var out strings.Builder
+		_, err := io.Copy(&out, s)
+		require.NoError(t, err)
+		assert.Equal(t, want, out.String())
+
+		files, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Greater(t, len(files), 10)
+
+		require.NoError(t, s.Close())
+		files, err = os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, files)
+	})
+
+	t.Run("merge passes", func(t *testing.T) {
+		dir := t.TempDir()
+		s := Echo(input).SortWith(SortOptions{MemoryLimit: 1, TempDir: dir})
+
+		var out strings.Builder
+		_, err := io.Copy(&out, s)
+		require.NoError(t, err)
+		assert.Equal(t, want, out.String())
+
+		// The files were merged until they could be merged at once.
+		files, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(files), maxMergeFiles)
+
+		require.NoError(t, s.Close())
+		files, err = os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, files)
+	})
+
+	t.Run("reversed", func(t *testing.T) {
+		out, err := Echo(input).SortWith(SortOptions{Reverse: true, MemoryLimit: 100, TempDir: t.TempDir()}).ToString()
+		require.NoError(t, err)
+		assert.Equal(t, wantReversed, out)
+	})
+
+	t.Run("closed early", func(t *testing.T) {
+		dir := t.TempDir()
+		out, err := Echo(input).SortWith(SortOptions{MemoryLimit: 100, TempDir: dir}).Head(1).ToString()
+		require.NoError(t, err)
+		assert.Equal(t, "0\n", out)
+
+		files, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, files)
+	})
+
+	t.Run("fits in memory", func(t *testing.T) {
+		dir := t.TempDir()
+		s := Echo(input).SortWith(SortOptions{MemoryLimit: 1 << 20, TempDir: dir})
+		out, err := s.ToString()
+		require.NoError(t, err)
+		assert.Equal(t, want, out)
+
+		files, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, files)
+	})
 }
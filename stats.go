@@ -0,0 +1,185 @@
+package script
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// StageStats are the measurements of a stage in a stream.
+type StageStats struct {
+	// Name is the name of the stage.
+	Name string
+	// BytesIn is the number of bytes the stage read from the previous stage.
+	BytesIn int64
+	// BytesOut is the number of bytes that were read from the output of the stage.
+	BytesOut int64
+	// LinesOut is the number of lines that were read from the output of the stage. An unterminated
+	// last line is counted as well.
+	LinesOut int64
+	// Duration is the time the stage spent in producing its output: the time that reading its
+	// output took, without the time that the stage spent in reading its input. It does not include
+	// the time before the output was first read. For a command, which runs concurrently with the
+	// other stages, it is an approximation.
+	Duration time.Duration
+	// Err is the error of the stage, once it was closed.
+	Err error
+}
+
+// Stats returns the measurements of all the stages of the stream, ordered by their position in the
+// stream. The measurements are complete after the stream was written with `To`, or any of its
+// variants, or closed; before that, they describe the progress of the stream so far.
+//
+// For example, the stage that takes most of the time of a stream is found with:
+//
+//	s := script.Cat("app.log").Grep(re).Modify(parse).Sort(false)
+//	err := s.ToFile("out.txt")
+//	for _, stage := range s.Stats() {
+//		fmt.Printf("%s: %s\n", stage.Name, stage.Duration)
+//	}
+func (s Stream) Stats() []StageStats {
+	var stats []StageStats
+	for cur := &s; cur != nil; cur = cur.parent {
+		// A stream without a reader, such as the source of `Exec`, is not a stage.
+		if cur.r == nil {
+			continue
+		}
+		stats = append(stats, cur.stats.get(cur.stage))
+	}
+	for i, j := 0, len(stats)-1; i < j; i, j = i+1, j-1 {
+		stats[i], stats[j] = stats[j], stats[i]
+	}
+	return stats
+}
+
+// measure starts measuring the current stage of the stream, and notifies the stream's tracer
+// that the stage started. The metadata describes the stage for the tracer.
+func (s Stream) measure(metadata map[string]string) Stream {
+	s.stats = &stageStats{}
+	if s.parent != nil {
+		s.stats.in = s.parent.stats
+	}
+	if s.r != nil {
+		if s.stats.tracer = s.getTracer(); s.stats.tracer != nil {
+			s.stats.info = StageInfo{Name: s.stage, Index: s.index, Metadata: metadata}
+			s.stats.tracer.StageStart(s.stats.info)
+		}
+		if d := s.getDebug(); d != nil {
+			w, err := d.open(s.index, s.stage)
+			if err != nil {
+				s.err = errors.Join(s.err, err)
+			}
+			s.stats.debug = w
+		}
+		s.r = &statsReader{r: s.r, stats: s.stats}
+	}
+	return s
+}
+
+// stageStats collects the measurements of a stage.
+type stageStats struct {
+	// in, if not nil, measures the previous stage, which is the input of the stage.
+	in *stageStats
+	// tracer, if not nil, is notified when the stage is closed.
+	tracer Tracer
+	info   StageInfo
+	// debug, if not nil, gets a copy of the output of the stage.
+	debug io.WriteCloser
+
+	mu       sync.Mutex
+	bytesOut int64
+	linesOut int64
+	// partial is set when the output that was read so far ends with an unterminated line.
+	partial bool
+	// busy is the time that was spent in reading the output of the stage.
+	busy   time.Duration
+	err    error
+	closed bool
+}
+
+// read records data that was read from the output of the stage, and the time reading it took.
+func (s *stageStats) read(b []byte, err error, d time.Duration) {
+	if s.debug != nil {
+		s.debug.Write(b)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bytesOut += int64(len(b))
+	s.linesOut += int64(bytes.Count(b, []byte{'\n'}))
+	if len(b) > 0 {
+		s.partial = b[len(b)-1] != '\n'
+	}
+	s.busy += d
+}
+
+// close records the closing of the stage with its error.
+func (s *stageStats) close(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.err = err
+	closed := s.closed
+	s.closed = true
+	s.mu.Unlock()
+
+	if s.debug != nil && !closed {
+		s.debug.Close()
+	}
+
+	if s.tracer != nil && !closed {
+		if err != nil {
+			s.tracer.Error(s.info, err)
+		}
+		s.tracer.StageEnd(s.info, s.get(s.info.Name))
+	}
+}
+
+func (s *stageStats) get(name string) StageStats {
+	stats := StageStats{Name: name}
+	if s == nil {
+		return stats
+	}
+	// The input of the stage is read while its output is read.
+	var inBusy time.Duration
+	if s.in != nil {
+		s.in.mu.Lock()
+		stats.BytesIn = s.in.bytesOut
+		inBusy = s.in.busy
+		s.in.mu.Unlock()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats.BytesOut = s.bytesOut
+	stats.LinesOut = s.linesOut
+	if s.partial {
+		stats.LinesOut++
+	}
+	if s.busy > inBusy {
+		stats.Duration = s.busy - inBusy
+	}
+	stats.Err = s.err
+	return stats
+}
+
+// statsReader measures the output of a stage.
+type statsReader struct {
+	r     io.Reader
+	stats *stageStats
+}
+
+func (r *statsReader) Read(b []byte) (int, error) {
+	start := time.Now()
+	n, err := r.r.Read(b)
+	r.stats.read(b[:n], err, time.Since(start))
+	return n, err
+}
+
+func (r *statsReader) Close() error {
+	if closer, ok := r.r.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package script
+
+import (
+	"errors"
+	"io"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+	t.Parallel()
+
+	s := Echo("a\nbb\na\nccc").
+		Grep(regexp.MustCompile(".")).
+		Modify(ModifyFn(func(line []byte) ([]byte, error) {
+			if line == nil {
+				return nil, nil
+			}
+			time.Sleep(10 * time.Millisecond)
+			return append(line, '\n'), nil
+		})).
+		Head(10)
+	out, err := s.ToString()
+	require.NoError(t, err)
+	assert.Equal(t, "a\nbb\na\nccc\n", out)
+
+	stats := s.Stats()
+	require.Len(t, stats, 4)
+	names := []string{stats[0].Name, stats[1].Name, stats[2].Name, stats[3].Name}
+	assert.Equal(t, []string{"echo", s.parent.parent.stage, "ModifyFn", s.stage}, names)
+	for i, stage := range stats {
+		assert.Equal(t, int64(11), stage.BytesOut, "stage %d", i)
+		assert.Equal(t, int64(4), stage.LinesOut, "stage %d", i)
+		assert.NoError(t, stage.Err, "stage %d", i)
+		if i > 0 {
+			assert.Equal(t, int64(11), stage.BytesIn, "stage %d", i)
+		}
+	}
+	assert.Equal(t, int64(0), stats[0].BytesIn)
+	assert.GreaterOrEqual(t, stats[2].Duration, 40*time.Millisecond)
+	// The time of the slow stage is not included in the following stage.
+	assert.Less(t, stats[3].Duration, 40*time.Millisecond)
+}
+
+func TestStats_idle(t *testing.T) {
+	t.Parallel()
+
+	// The time before the stream is read is not measured.
+	s := Exec("echo", "a").Modify(ModifyFn(func(line []byte) ([]byte, error) { return line, nil })).Sort(false)
+	time.Sleep(200 * time.Millisecond)
+	require.NoError(t, s.Discard())
+
+	stats := s.Stats()
+	require.Len(t, stats, 3)
+	for _, stage := range stats {
+		assert.Less(t, stage.Duration, 200*time.Millisecond, stage.Name)
+	}
+}
+
+func TestStats_exec(t *testing.T) {
+	t.Parallel()
+
+	s := Exec("echo", "a").Head(1)
+	require.NoError(t, s.Discard())
+
+	stats := s.Stats()
+	require.Len(t, stats, 2)
+	assert.Equal(t, "exec(echo, [a])", stats[0].Name)
+	assert.Equal(t, int64(2), stats[0].BytesOut)
+}
+
+func TestStats_error(t *testing.T) {
+	t.Parallel()
+
+	s := Writer("writer", func(w io.Writer) error {
+		io.WriteString(w, "a\nb")
+		return errors.New("oops")
+	}).Head(5)
+	err := s.Discard()
+	require.Error(t, err)
+
+	stats := s.Stats()
+	require.Len(t, stats, 2)
+	assert.EqualError(t, stats[0].Err, "oops")
+	assert.Equal(t, int64(2), stats[0].LinesOut)
+	assert.NoError(t, stats[1].Err)
+	assert.Equal(t, int64(2), stats[1].LinesOut)
+}
@@ -0,0 +1,112 @@
+package script
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ExecSplit executes a command and returns two streams: one of the stdout of the command and one of
+// its stderr.
+//
+// The stderr is buffered in memory, and the stdout is not. The stdout stream must be read before
+// the stderr stream, or concurrently with it: if the stderr stream is read first, it blocks once the
+// command fills the pipe of its stdout. The command is waited for, and its errors are reported, by
+// the stdout stream.
+func ExecSplit(cmd string, args ...string) (stdout, stderr Stream) {
+	return From("empty", nil).ExecSplit(cmd, args...)
+}
+
+// ExecSplit executes a command and returns two streams: one of the stdout of the command and one of
+// its stderr. See the `ExecSplit` function.
+func (s Stream) ExecSplit(cmd string, args ...string) (stdout, stderr Stream) {
+	buf := newBufPipe()
+	e := exe{cmd: cmd, args: args, stderrOut: buf}
+	stdout = s.Through(e)
+	stderr = s.options()
+	stderr.stage = fmt.Sprintf("stderr(%s)", e.Name())
+	stderr.r = buf
+	stderr.source = &stdout
+	return stdout, stderr.measure(nil)
+}
+
+// copyLines copies from a reader to a writer, and writes each line in a single write. An
+// unterminated last line is not written, and is returned as the tail, such that it won't be
+// followed by lines of other writers.
+func copyLines(w io.Writer, r io.Reader) (tail []byte, err error) {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			return line, nil
+		}
+		if len(line) > 0 {
+			if _, werr := w.Write(line); werr != nil {
+				return nil, werr
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// bufPipe is an in-memory pipe with an unbounded buffer. Writes never block, and reads block until
+// data was written or the pipe was closed for writing.
+type bufPipe struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	buf  bytes.Buffer
+	// writeClosed is set when there will be no more writes.
+	writeClosed bool
+	// readClosed is set when there will be no more reads, and writes can be discarded.
+	readClosed bool
+}
+
+func newBufPipe() *bufPipe {
+	p := &bufPipe{}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+func (p *bufPipe) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.readClosed {
+		p.buf.Write(b)
+		p.cond.Broadcast()
+	}
+	return len(b), nil
+}
+
+// CloseWrite marks the end of the written data.
+func (p *bufPipe) CloseWrite() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.writeClosed = true
+	p.cond.Broadcast()
+}
+
+func (p *bufPipe) Read(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for p.buf.Len() == 0 && !p.writeClosed && !p.readClosed {
+		p.cond.Wait()
+	}
+	if p.buf.Len() == 0 {
+		return 0, io.EOF
+	}
+	return p.buf.Read(b)
+}
+
+// Close closes the reading side of the pipe.
+func (p *bufPipe) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.readClosed = true
+	p.buf.Reset()
+	p.cond.Broadcast()
+	return nil
+}
@@ -0,0 +1,89 @@
+package script
+
+import (
+	"errors"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecSplit(t *testing.T) {
+	t.Parallel()
+
+	const script = "echo out; head -c 100000 /dev/zero | tr '\\0' x >&2; echo >&2; echo err >&2; exit 1"
+
+	t.Run("stdout first", func(t *testing.T) {
+		stdout, stderr := ExecSplit("sh", "-c", script)
+
+		out, err := stdout.ToString()
+		var execErr *ExecError
+		require.True(t, errors.As(err, &execErr))
+		assert.Equal(t, "out\n", out)
+		assert.EqualError(t, execErr, "exit status 1: err")
+
+		errOut, err := stderr.Grep(regexp.MustCompile("err")).ToString()
+		require.NoError(t, err)
+		assert.Equal(t, "err\n", errOut)
+	})
+
+	t.Run("concurrently", func(t *testing.T) {
+		const script = "head -c 200000 /dev/zero; echo err >&2"
+		stdout, stderr := ExecSplit("sh", "-c", script)
+
+		var (
+			errOut string
+			errErr error
+			done   = make(chan struct{})
+		)
+		go func() {
+			defer close(done)
+			errOut, errErr = stderr.ToString()
+		}()
+		out, err := stdout.ToString()
+		<-done
+
+		require.NoError(t, err)
+		assert.Len(t, out, 200000)
+		require.NoError(t, errErr)
+		assert.Equal(t, "err\n", errOut)
+	})
+
+	t.Run("with stdin", func(t *testing.T) {
+		stdout, stderr := Echo("hello").ExecSplit("sh", "-c", "cat >&2")
+
+		out, err := stdout.ToString()
+		require.NoError(t, err)
+		assert.Equal(t, "", out)
+
+		errOut, err := stderr.ToString()
+		require.NoError(t, err)
+		assert.Equal(t, "hello\n", errOut)
+	})
+}
+
+func TestExec_mergeStderr(t *testing.T) {
+	t.Parallel()
+
+	script := "for i in 1 2 3 4 5; do echo out$i; echo err$i >&2; done; printf last"
+	out, err := ExecWith(ExecOptions{MergeStderr: true}, "sh", "-c", script).ToString()
+	require.NoError(t, err)
+
+	lines := strings.Split(out, "\n")
+	sort.Strings(lines)
+	assert.Equal(t, []string{"err1", "err2", "err3", "err4", "err5", "last", "out1", "out2", "out3", "out4", "out5"}, lines)
+}
+
+func TestExec_mergeStderrStoppedEarly(t *testing.T) {
+	t.Parallel()
+
+	s := ExecWith(ExecOptions{MergeStderr: true}, "sh", "-c", "yes & yes >&2")
+	b := make([]byte, 10)
+	_, err := io.ReadFull(s, b)
+	require.NoError(t, err)
+	assert.NoError(t, s.Close())
+}
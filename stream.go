@@ -15,9 +15,13 @@
 package script
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"io"
 	"reflect"
+	"strings"
+	"time"
 )
 
 // Stream is a chain of operations on a stream of bytes. The stdout of each operation in the stream
@@ -35,10 +39,42 @@ type Stream struct {
 	r io.Reader
 	// stage is the name of the current stage in the stream.
 	stage string
+	// shell is the shell command that is equivalent to the current stage, or empty if there is
+	// none.
+	shell string
 	// parent points to the stage before the current stage in the stream.
 	parent *Stream
+	// source points to the stage that the current stage was split from, for a stage that has no
+	// parent, such as the stderr of `ExecSplit`. Unlike the parent, it is not closed with the
+	// stream.
+	source *Stream
+	// size is the total size of the output of the current stage if it is known in advance, or 0.
+	size int64
+	// index is the position of the current stage in the stream, starting from 0. A stream without
+	// a reader, such as the source of `Exec`, is not counted as a stage.
+	index int
 	// err contains an error from the current stage in the stream.
 	err error
+	// ctx is the context that the stream is bound to. It is nil if the stream is not bound to a
+	// context.
+	ctx context.Context
+	// timeout is the default timeout of the stages that are added after the current stage.
+	timeout time.Duration
+	// failPolicy defines which of the stages' errors are reported by `Close`.
+	failPolicy FailPolicy
+	// timer, if not nil, limits the running time of the current stage.
+	timer *stageTimer
+	// trace, if not nil, logs the stages that are added after the current stage.
+	trace io.Writer
+	// dryRun, if not nil, makes the stages that are added after the current stage report what they
+	// would do instead of doing it.
+	dryRun io.Writer
+	// stats, if not nil, measures the current stage.
+	stats *stageStats
+	// tracer, if not nil, is notified about the stages that are added after the current stage.
+	tracer Tracer
+	// debug, if not nil, copies the output of the stages that are added after the current stage.
+	debug *debugger
 }
 
 // Read can be used to read from the stream.
@@ -47,35 +83,145 @@ func (s Stream) Read(b []byte) (int, error) {
 }
 
 // Close closes all the stages in the stream and return the errors that occurred in all of the
-// stages.
+// stages. The error of each stage is wrapped in a `*StageError`, and the errors are ordered by the
+// position of their stage in the stream. Which of the errors are reported is defined by the stream's
+// `FailPolicy`.
 func (s Stream) Close() error {
-	var merr error
+	var errs []error
 	for cur := &s; cur != nil; cur = cur.parent {
-		if cur.err != nil {
-			merr = errors.Join(merr, cur.err)
+		err := cur.close()
+		if s.failPolicy == FailLast && cur != &s {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, &StageError{Stage: cur.stage, Index: cur.index, Err: err})
+		}
+	}
+	// Errors were collected from the last stage to the first one.
+	for i, j := 0, len(errs)-1; i < j; i, j = i+1, j-1 {
+		errs[i], errs[j] = errs[j], errs[i]
+	}
+	// Report if the stream was cancelled.
+	if s.ctx != nil && s.ctx.Err() != nil {
+		errs = append(errs, context.Cause(s.ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// close closes the current stage, and returns the errors that occurred in it.
+func (s *Stream) close() error {
+	var merr error
+	if s.err != nil {
+		merr = errors.Join(merr, s.err)
+	}
+	if closer, ok := s.r.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			merr = errors.Join(merr, err)
 		}
-		if closer, ok := cur.r.(io.Closer); ok {
-			if err := closer.Close(); err != nil {
-				merr = errors.Join(merr, err)
-			}
+	}
+	// The timer is released only after the stage was closed, since releasing it cancels the
+	// stage's context.
+	if s.timer != nil {
+		if err := s.timer.close(); err != nil {
+			merr = errors.Join(merr, err)
 		}
 	}
+	s.stats.close(merr)
 	return merr
 }
 
 // Through passes the current stream through a pipe. This function can be used to add custom
 // commands that are not available in this library.
 func (s Stream) Through(pipe Pipe) Stream {
-	r, err := pipe.Pipe(s.r)
+	return s.through(pipe, s.timeout)
+}
+
+// through passes the current stream through a pipe that is limited to run for the given timeout.
+// A zero timeout means no limit.
+func (s Stream) through(pipe Pipe, timeout time.Duration) Stream {
+	trace, dryRun := s.tracing()
+	if trace != nil {
+		fmt.Fprintf(trace, "+ %s\n", describe(pipe))
+	}
+	if dp, ok := pipe.(dryRunPipe); ok && dryRun != nil {
+		pipe = dp.withDryRun(dryRun)
+	}
+
+	ctx := s.ctx
+	var timer *stageTimer
+	if timeout > 0 {
+		ctx, timer = newStageTimer(ctx, pipe.Name(), s.nextIndex(), timeout)
+		if tp, ok := pipe.(timedPipe); ok {
+			pipe = tp.withTimer(timer)
+		}
+	}
+
+	// A stream without a reader has no output. Commands run without stdin, and other pipes read an
+	// empty input.
+	stdin := s.r
+	if _, ok := pipe.(exe); stdin == nil && !ok {
+		stdin = strings.NewReader("")
+	}
+	r, err := pipeContext(ctx, pipe, stdin)
 	if r == nil {
 		panic("a command must contain a reader")
 	}
+	if timer != nil {
+		r = timedReader{r: r, timer: timer}
+	}
+	next := s.options()
+	next.stage = pipe.Name()
+	if sheller, ok := pipe.(Sheller); ok {
+		next.shell = sheller.Shell()
+	}
+	next.r = r
+	next.err = err
+	next.parent = &s
+	next.index = s.nextIndex()
+	next.timer = timer
+	return next.measure(pipeMetadata(pipe))
+}
+
+// options returns a stream without stages, with the options of the current stream that are
+// inherited by the stages that are added after it.
+func (s Stream) options() Stream {
 	return Stream{
-		stage:  pipe.Name(),
-		r:      r,
-		err:    err,
-		parent: &s,
+		ctx:        s.ctx,
+		timeout:    s.timeout,
+		failPolicy: s.failPolicy,
+		trace:      s.trace,
+		dryRun:     s.dryRun,
+		tracer:     s.tracer,
+		debug:      s.debug,
+	}
+}
+
+// nextIndex returns the index of a stage that is added after the current stage.
+func (s Stream) nextIndex() int {
+	if s.r == nil {
+		return s.index
 	}
+	return s.index + 1
+}
+
+// FailPolicy defines which failures of stages in a stream fail the stream.
+type FailPolicy int
+
+const (
+	// FailAny fails the stream when any of its stages fails, like `set -o pipefail` in bash. This
+	// is the default policy.
+	FailAny FailPolicy = iota
+	// FailLast fails the stream only when its last stage fails, like the default behavior of a
+	// pipeline in a shell.
+	FailLast
+)
+
+// Pipefail sets the policy by which the stream's `Close` reports errors of the stream stages. The
+// policy of the stream that `Close` is called on is used, and it is inherited by stages that are
+// added after it.
+func (s Stream) Pipefail(policy FailPolicy) Stream {
+	s.failPolicy = policy
+	return s
 }
 
 // Pipe reads from a reader and returns another reader.
@@ -94,7 +240,51 @@ func (f PipeFn) Pipe(stdin io.Reader) (io.Reader, error) { return f(stdin) }
 
 func (f PipeFn) Name() string { return reflect.TypeOf(f).Name() }
 
+// StageError is an error that occurred in a stage of a stream. It can be extracted from the error
+// returned by the stream `Close` method using `errors.As`.
+type StageError struct {
+	// Stage is the name of the stage.
+	Stage string
+	// Index is the position of the stage in the stream, starting from 0.
+	Index int
+	// Err is the error that occurred in the stage.
+	Err error
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("stage %d (%s): %v", e.Index, e.Stage, e.Err)
+}
+
+func (e *StageError) Unwrap() error { return e.Err }
+
 type readcloser struct {
 	io.Reader
 	io.Closer
 }
+
+// lazyReader creates its reader on the first read, such that a stage does nothing until the stream
+// is read. The error of creating the reader is returned by `Close`.
+type lazyReader struct {
+	create func() (io.Reader, error)
+	r      io.Reader
+	err    error
+}
+
+func (l *lazyReader) Read(b []byte) (int, error) {
+	if l.create != nil {
+		l.r, l.err = l.create()
+		l.create = nil
+	}
+	return l.r.Read(b)
+}
+
+func (l *lazyReader) Close() error {
+	// Nothing to do if the reader was not created.
+	l.create = nil
+	if closer, ok := l.r.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			return errors.Join(l.err, err)
+		}
+	}
+	return l.err
+}
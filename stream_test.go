@@ -2,9 +2,15 @@ package script
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
+	"regexp"
 	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // A simple "hello world" example that creats a stream and pipe it to the stdout.
@@ -83,3 +89,47 @@ type readerFn func(b []byte) (int, error)
 func (f readerFn) Read(b []byte) (int, error) {
 	return f(b)
 }
+
+func TestStageError(t *testing.T) {
+	t.Parallel()
+
+	_, err := Echo("a").Exec("false").Grep(regexp.MustCompile("a")).ToString()
+	require.Error(t, err)
+
+	var stageErr *StageError
+	require.True(t, errors.As(err, &stageErr))
+	assert.Equal(t, "exec(false, [])", stageErr.Stage)
+	assert.Equal(t, 1, stageErr.Index)
+	assert.EqualError(t, err, "stage 1 (exec(false, [])): exit status 1")
+}
+
+func TestStageError_order(t *testing.T) {
+	t.Parallel()
+
+	err := Cat("no-such-file").Exec("false").Close()
+	require.Error(t, err)
+	assert.Regexp(t, "^stage 0 \\(cat\\): .*\nstage 1 \\(exec\\(false, \\[\\]\\)\\): exit status 1$", err.Error())
+}
+
+func TestPipefail(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fail any", func(t *testing.T) {
+		got, err := Exec("sh", "-c", "echo a; exit 1").Exec("cat").ToString()
+		assert.Error(t, err)
+		assert.Equal(t, "a\n", got)
+	})
+
+	t.Run("fail last", func(t *testing.T) {
+		got, err := Exec("sh", "-c", "echo a; exit 1").Exec("cat").Pipefail(FailLast).ToString()
+		assert.NoError(t, err)
+		assert.Equal(t, "a\n", got)
+	})
+
+	t.Run("fail last with failing last stage", func(t *testing.T) {
+		_, err := Echo("a").Pipefail(FailLast).Exec("false").ToString()
+		var stageErr *StageError
+		require.True(t, errors.As(err, &stageErr))
+		assert.Equal(t, 1, stageErr.Index)
+	})
+}
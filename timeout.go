@@ -0,0 +1,130 @@
+package script
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Timeout limits the running time of every stage that is added to the stream after it. A stage
+// that does not finish writing its output within the timeout is stopped, the same way that
+// stages are stopped when the stream's context is done, and `Close` reports which stage timed
+// out. The timeout of a stage starts when the stage first runs: when its command is started, or
+// when its output is first read. A zero timeout removes the limit.
+//
+// For example, the following stream kills `git fetch` if it runs for more than a minute, without
+// limiting the other stages:
+//
+//	s.Timeout(time.Minute).Exec("git", "fetch").Timeout(0).Exec(...)
+func (s Stream) Timeout(d time.Duration) Stream {
+	s.timeout = d
+	return s
+}
+
+// ThroughTimeout passes the current stream through a pipe, like `Through`, and limits the
+// running time of the pipe to the given timeout.
+func (s Stream) ThroughTimeout(pipe Pipe, d time.Duration) Stream {
+	return s.through(pipe, d)
+}
+
+// stageTimer cancels the context of a stage that runs for longer than its timeout.
+type stageTimer struct {
+	// err is the error that is reported by the stage's `Close` when the stage times out.
+	err error
+	// cause is the cause of the cancellation of the stage's context, it is reported to the
+	// readers of the stage.
+	cause   error
+	d       time.Duration
+	cancel  context.CancelCauseFunc
+	expired atomic.Bool
+
+	mu sync.Mutex
+	// timer is set when the stage starts, and stopped is set when the stage is done.
+	timer   *time.Timer
+	stopped bool
+}
+
+// timedPipe is implemented by pipes that start running before their output is read, and start
+// the timer of their stage themselves.
+type timedPipe interface {
+	Pipe
+	// withTimer returns a copy of the pipe that starts t when it starts running.
+	withTimer(t *stageTimer) Pipe
+}
+
+// newStageTimer returns a context derived from parent, which is cancelled if the stage runs for
+// longer than the given timeout. The timer is not started until `start` is called. A nil parent is
+// treated as the background context.
+func newStageTimer(parent context.Context, stage string, index int, d time.Duration) (context.Context, *stageTimer) {
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancelCause(parent)
+	err := fmt.Errorf("timed out after %v: %w", d, context.DeadlineExceeded)
+	t := &stageTimer{
+		err:    err,
+		cause:  &StageError{Stage: stage, Index: index, Err: err},
+		d:      d,
+		cancel: cancel,
+	}
+	return ctx, t
+}
+
+// start starts the timer, if it was not started or stopped already.
+func (t *stageTimer) start() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.timer != nil || t.stopped {
+		return
+	}
+	t.timer = time.AfterFunc(t.d, func() {
+		t.expired.Store(true)
+		t.cancel(t.cause)
+	})
+}
+
+// stop stops the timer, it should be called when the stage is done.
+func (t *stageTimer) stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stopped = true
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+}
+
+// close releases the timer and returns an error if the stage timed out.
+func (t *stageTimer) close() error {
+	t.stop()
+	t.cancel(nil)
+	if t.expired.Load() {
+		return t.err
+	}
+	return nil
+}
+
+// timedReader starts the timer of a stage when its output is first read, and stops it once the
+// output is done.
+type timedReader struct {
+	r     io.Reader
+	timer *stageTimer
+}
+
+func (t timedReader) Read(b []byte) (int, error) {
+	t.timer.start()
+	n, err := t.r.Read(b)
+	if err != nil {
+		t.timer.stop()
+	}
+	return n, err
+}
+
+func (t timedReader) Close() error {
+	if closer, ok := t.r.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
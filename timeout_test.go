@@ -0,0 +1,49 @@
+package script
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeout(t *testing.T) {
+	t.Parallel()
+
+	t.Run("stage timed out", func(t *testing.T) {
+		start := time.Now()
+		_, err := Echo("a").Timeout(100*time.Millisecond).Exec("sleep", "10").Timeout(0).Exec("cat").ToString()
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.EqualError(t, err, "stage 1 (exec(sleep, [10])): timed out after 100ms: context deadline exceeded")
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("through timeout", func(t *testing.T) {
+		_, err := Echo("a").ThroughTimeout(exe{cmd: "sleep", args: []string{"10"}}, 100*time.Millisecond).ToString()
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.EqualError(t, err, "stage 1 (exec(sleep, [10])): timed out after 100ms: context deadline exceeded")
+	})
+
+	t.Run("finished stage", func(t *testing.T) {
+		s := Echo("a").Timeout(50 * time.Millisecond).Exec("cat")
+		got, err := io.ReadAll(s)
+		require.NoError(t, err)
+		assert.Equal(t, "a\n", string(got))
+
+		time.Sleep(100 * time.Millisecond)
+		assert.NoError(t, s.Close())
+	})
+
+	t.Run("lazy stage", func(t *testing.T) {
+		// The timer starts when the stage is first read, not when the stream is built.
+		s := Echo("b\na").Timeout(50 * time.Millisecond).Sort(false)
+		time.Sleep(100 * time.Millisecond)
+		got, err := s.ToString()
+		require.NoError(t, err)
+		assert.Equal(t, "a\nb\n", got)
+	})
+
+}
@@ -2,21 +2,53 @@ package script
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 // To writes the output of the stream to an io.Writer and closes it.
 func (s Stream) To(w io.Writer) error {
+	return s.to(w, "to", nil)
+}
+
+// to writes the output of the stream to a writer and closes it. The writing is reported to the
+// stream's tracer as a stage with the given name and metadata.
+func (s Stream) to(w io.Writer, name string, metadata map[string]string) error {
+	tracer := s.getTracer()
+	info := StageInfo{Name: name, Index: s.nextIndex(), Metadata: metadata}
+	start := time.Now()
+	if tracer != nil {
+		tracer.StageStart(info)
+	}
+
 	var merr error
-	if _, err := io.Copy(w, s); err != nil {
-		merr = errors.Join(merr, err)
+	n, copyErr := io.Copy(w, s)
+	// Errors of stages, such as a timeout, and the cancellation of the stream are reported by
+	// `Close`.
+	var stageErr *StageError
+	cancelled := s.ctx != nil && s.ctx.Err() != nil && errors.Is(copyErr, context.Cause(s.ctx))
+	if errors.As(copyErr, &stageErr) || cancelled {
+		copyErr = nil
+	}
+	if copyErr != nil {
+		merr = errors.Join(merr, copyErr)
 	}
 	if err := s.Close(); err != nil {
 		merr = errors.Join(merr, err)
 	}
+
+	// The errors of the stages were reported by the stages, only the writing errors are reported
+	// by the sink.
+	if tracer != nil {
+		if copyErr != nil {
+			tracer.Error(info, copyErr)
+		}
+		tracer.StageEnd(info, StageStats{Name: name, BytesIn: n, Duration: time.Since(start), Err: copyErr})
+	}
 	return merr
 }
 
@@ -41,23 +73,35 @@ func (s Stream) ToString() (string, error) {
 }
 
 // ToFile dumps the output of the stream to a file.
+//
+// In dry-run mode, the path is reported and the file is not written. See `Stream.DryRun`.
 func (s Stream) ToFile(path string) error {
+	if _, dryRun := s.tracing(); dryRun != nil {
+		reportFile(dryRun, path, false)
+		return s.Discard()
+	}
 	f, err := File(path)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
-	return s.To(f)
+	return s.to(f, "to-file", map[string]string{"path": path})
 }
 
 // AppendFile appends the output of the stream to a file.
+//
+// In dry-run mode, the path is reported and the file is not written. See `Stream.DryRun`.
 func (s Stream) AppendFile(path string) error {
+	if _, dryRun := s.tracing(); dryRun != nil {
+		reportFile(dryRun, path, true)
+		return s.Discard()
+	}
 	f, err := AppendFile(path)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
-	return s.To(f)
+	return s.to(f, "append-file", map[string]string{"path": path})
 }
 
 // ToTempFile dumps the output of the stream to a temporary file and returns the temporary files'
@@ -0,0 +1,152 @@
+package script
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// tracing holds the tracing options of all the streams.
+var tracing = struct {
+	sync.RWMutex
+	trace, dryRun io.Writer
+	tracer        Tracer
+}{}
+
+// SetTrace logs every stage of all the streams to w as it starts, like `set -x` in a shell. The
+// log line of a stage contains its name, and the working directory of commands that don't run in
+// the current directory. A nil writer stops the tracing. A stream's own tracing, set with
+// `Stream.Trace`, takes precedence.
+func SetTrace(w io.Writer) {
+	tracing.Lock()
+	defer tracing.Unlock()
+	tracing.trace = w
+}
+
+// SetDryRun sets all the streams to dry-run mode, in which commands and files are reported to w
+// instead of being executed or written. A nil writer stops the dry-run mode. See `Stream.DryRun`.
+func SetDryRun(w io.Writer) {
+	tracing.Lock()
+	defer tracing.Unlock()
+	tracing.dryRun = w
+}
+
+// Trace logs the stages that are added after the current stage to w as they start. See `SetTrace`.
+func (s Stream) Trace(w io.Writer) Stream {
+	s.trace = w
+	return s
+}
+
+// DryRun sets the stages that are added after the current stage to dry-run mode: commands are
+// reported to w instead of being executed and have an empty output, and `ToFile`, `AppendFile`
+// and file redirections of parsed pipelines report the file path instead of writing to it. Other
+// stages run as usual.
+//
+// Since sources run as soon as they are created, dry-run mode should be set before a command
+// source, for example `From("", nil).DryRun(os.Stderr).Exec("kubectl", "apply")`, or globally
+// with `SetDryRun`.
+func (s Stream) DryRun(w io.Writer) Stream {
+	s.dryRun = w
+	return s
+}
+
+// tracing returns the writers for tracing and dry-run reports of the stream, or nil if they are
+// not enabled.
+func (s Stream) tracing() (trace, dryRun io.Writer) {
+	tracing.RLock()
+	defer tracing.RUnlock()
+	trace, dryRun = s.trace, s.dryRun
+	if trace == nil {
+		trace = tracing.trace
+	}
+	if dryRun == nil {
+		dryRun = tracing.dryRun
+	}
+	return trace, dryRun
+}
+
+// Tracer is notified about the stages of streams, for example to export them to a tracing system.
+// The methods of a tracer may be called concurrently by different streams.
+type Tracer interface {
+	// StageStart is called when a stage starts, which is when it is added to the stream.
+	StageStart(stage StageInfo)
+	// StageEnd is called when a stage is closed, with its measurements.
+	StageEnd(stage StageInfo, stats StageStats)
+	// Error is called when a stage is closed with an error, before `StageEnd` is called.
+	Error(stage StageInfo, err error)
+}
+
+// StageInfo describes a stage for a `Tracer`.
+type StageInfo struct {
+	// Name is the name of the stage.
+	Name string
+	// Index is the position of the stage in the stream, starting from 0.
+	Index int
+	// Metadata holds details about the stage, such as "cmd", "args" and "dir" of commands, "paths"
+	// of `Cat` and `Ls`, and "path" of files that are written. It may be nil.
+	Metadata map[string]string
+}
+
+// SetTracer sets a tracer that is notified about the stages of all the streams. A nil tracer
+// removes it. A stream's own tracer, set with `Stream.WithTracer`, takes precedence.
+func SetTracer(t Tracer) {
+	tracing.Lock()
+	defer tracing.Unlock()
+	tracing.tracer = t
+}
+
+// WithTracer sets a tracer that is notified about the stages that are added after the current
+// stage, and about writing the stream with `To`, or any of its variants.
+func (s Stream) WithTracer(t Tracer) Stream {
+	s.tracer = t
+	return s
+}
+
+// getTracer returns the tracer of the stream, or nil if it has none.
+func (s Stream) getTracer() Tracer {
+	if s.tracer != nil {
+		return s.tracer
+	}
+	tracing.RLock()
+	defer tracing.RUnlock()
+	return tracing.tracer
+}
+
+// pipeMetadata returns the tracing metadata of a pipe.
+func pipeMetadata(pipe Pipe) map[string]string {
+	switch p := pipe.(type) {
+	case exe:
+		metadata := map[string]string{"cmd": p.cmd, "args": quoteAll(p.args)}
+		if p.opts.Dir != "" {
+			metadata["dir"] = p.opts.Dir
+		}
+		return metadata
+	case fileSink:
+		return map[string]string{"path": p.path}
+	}
+	return nil
+}
+
+// dryRunPipe is implemented by pipes that have side effects, which are skipped in dry-run mode.
+type dryRunPipe interface {
+	Pipe
+	// withDryRun returns a copy of the pipe that reports to w what it would do.
+	withDryRun(w io.Writer) Pipe
+}
+
+// describe returns a description of a pipe for tracing.
+func describe(pipe Pipe) string {
+	if e, ok := pipe.(exe); ok && e.opts.Dir != "" {
+		return fmt.Sprintf("%s (dir: %s)", e.Name(), e.opts.Dir)
+	}
+	return pipe.Name()
+}
+
+// reportFile reports a file that would be written in dry-run mode.
+func reportFile(w io.Writer, path string, append bool) {
+	if append {
+		fmt.Fprintf(w, "dry-run: append to %s\n", path)
+		return
+	}
+	fmt.Fprintf(w, "dry-run: write to %s\n", path)
+}
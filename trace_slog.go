@@ -0,0 +1,56 @@
+//go:build go1.21
+
+package script
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+)
+
+// SlogTracer is a `Tracer` that logs the stages of streams with a structured logger. The start of
+// a stage is logged in debug level, its end in info level, and its errors in error level.
+type SlogTracer struct {
+	Logger *slog.Logger
+}
+
+// NewSlogTracer returns a tracer that logs with the given logger. If the logger is nil, the
+// default logger is used.
+func NewSlogTracer(logger *slog.Logger) SlogTracer {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return SlogTracer{Logger: logger}
+}
+
+func (t SlogTracer) StageStart(stage StageInfo) {
+	t.Logger.LogAttrs(context.Background(), slog.LevelDebug, "stage started", stageAttrs(stage)...)
+}
+
+func (t SlogTracer) StageEnd(stage StageInfo, stats StageStats) {
+	attrs := append(stageAttrs(stage),
+		slog.Int64("bytes_in", stats.BytesIn),
+		slog.Int64("bytes_out", stats.BytesOut),
+		slog.Int64("lines_out", stats.LinesOut),
+		slog.Duration("duration", stats.Duration),
+	)
+	t.Logger.LogAttrs(context.Background(), slog.LevelInfo, "stage ended", attrs...)
+}
+
+func (t SlogTracer) Error(stage StageInfo, err error) {
+	attrs := append(stageAttrs(stage), slog.Any("error", err))
+	t.Logger.LogAttrs(context.Background(), slog.LevelError, "stage failed", attrs...)
+}
+
+func stageAttrs(stage StageInfo) []slog.Attr {
+	attrs := []slog.Attr{slog.String("stage", stage.Name), slog.Int("index", stage.Index)}
+	keys := make([]string, 0, len(stage.Metadata))
+	for key := range stage.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		attrs = append(attrs, slog.String(key, stage.Metadata[key]))
+	}
+	return attrs
+}
@@ -0,0 +1,39 @@
+//go:build go1.21
+
+package script
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlogTracer(t *testing.T) {
+	t.Parallel()
+
+	var log bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&log, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey || a.Key == "duration" {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+	err := From("", nil).WithTracer(NewSlogTracer(logger)).Exec("false").Discard()
+	require.Error(t, err)
+
+	assert.Equal(t, []string{
+		`level=DEBUG msg="stage started" stage="exec(false, [])" index=0 args="" cmd=false`,
+		`level=DEBUG msg="stage started" stage=to index=1`,
+		`level=ERROR msg="stage failed" stage="exec(false, [])" index=0 args="" cmd=false error="exit status 1"`,
+		`level=INFO msg="stage ended" stage="exec(false, [])" index=0 args="" cmd=false bytes_in=0 bytes_out=0 lines_out=0`,
+		`level=INFO msg="stage ended" stage=to index=1 bytes_in=0 bytes_out=0 lines_out=0`,
+	}, strings.Split(strings.TrimSpace(log.String()), "\n"))
+}
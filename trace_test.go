@@ -0,0 +1,133 @@
+package script
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrace(t *testing.T) {
+	t.Parallel()
+
+	var trace bytes.Buffer
+	out, err := Echo("b\na").Trace(&trace).
+		ExecWith(ExecOptions{Dir: "testdata"}, "cat").
+		Sort(false).
+		ToString()
+
+	require.NoError(t, err)
+	assert.Equal(t, "a\nb\n", out)
+	assert.Equal(t, "+ exec(cat, []) (dir: testdata)\n+ sort(false)\n", trace.String())
+}
+
+func TestDryRun(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.txt")
+
+	t.Run("exec", func(t *testing.T) {
+		var report bytes.Buffer
+		out, err := From("", nil).DryRun(&report).Exec("rm", path).Exec("cat").ToString()
+
+		require.NoError(t, err)
+		assert.Equal(t, "", out)
+		assert.Equal(t, "dry-run: exec(rm, ["+path+"])\ndry-run: exec(cat, [])\n", report.String())
+	})
+
+	t.Run("split stderr", func(t *testing.T) {
+		var report bytes.Buffer
+		stdout, stderr := From("", nil).DryRun(&report).ExecSplit("echo", "x")
+		require.NoError(t, stdout.Discard())
+		require.NoError(t, stderr.Exec("touch", path).Discard())
+		assert.Equal(t, "dry-run: exec(echo, [x])\ndry-run: exec(touch, ["+path+"])\n", report.String())
+		assert.NoFileExists(t, path)
+	})
+
+	t.Run("to file", func(t *testing.T) {
+		var report bytes.Buffer
+		s := Echo("hello").DryRun(&report)
+
+		require.NoError(t, s.ToFile(path))
+		require.NoError(t, s.AppendFile(path))
+		assert.Equal(t, "dry-run: write to "+path+"\ndry-run: append to "+path+"\n", report.String())
+		assert.NoFileExists(t, path)
+	})
+
+	t.Run("parsed redirection", func(t *testing.T) {
+		var report bytes.Buffer
+		s := Echo("hello").DryRun(&report).Through(fileSink{path: path})
+		require.NoError(t, s.Discard())
+		assert.Equal(t, "dry-run: write to "+path+"\n", report.String())
+		assert.NoFileExists(t, path)
+	})
+}
+
+func TestSetDryRun(t *testing.T) {
+	// Not parallel, the dry-run mode applies to all the streams.
+	var trace, report bytes.Buffer
+	SetTrace(&trace)
+	SetDryRun(&report)
+	defer SetTrace(nil)
+	defer SetDryRun(nil)
+
+	out, err := Exec("echo", "hello").ToString()
+
+	require.NoError(t, err)
+	assert.Equal(t, "", out)
+	assert.Equal(t, "+ exec(echo, [hello])\n", trace.String())
+	assert.Equal(t, "dry-run: exec(echo, [hello])\n", report.String())
+}
+
+func TestTracer(t *testing.T) {
+	t.Parallel()
+
+	var tracer recordTracer
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.txt")
+	err := From("", nil).WithTracer(&tracer).
+		ExecWith(ExecOptions{Dir: dir}, "sh", "-c", "echo hello; exit 1").
+		ToFile(path)
+
+	require.Error(t, err)
+	assert.Equal(t, []string{
+		"start 0 exec(sh, [-c echo hello; exit 1]) map[args:-c 'echo hello; exit 1' cmd:sh dir:" + dir + "]",
+		"start 1 to-file map[path:" + path + "]",
+		"error 0 exec(sh, [-c echo hello; exit 1]): exit status 1",
+		"end 0 exec(sh, [-c echo hello; exit 1]) out=6",
+		"end 1 to-file in=6",
+	}, tracer.events)
+}
+
+// recordTracer records the events of a tracer.
+type recordTracer struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recordTracer) record(format string, args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, fmt.Sprintf(format, args...))
+}
+
+func (r *recordTracer) StageStart(stage StageInfo) {
+	r.record("start %d %s %v", stage.Index, stage.Name, stage.Metadata)
+}
+
+func (r *recordTracer) StageEnd(stage StageInfo, stats StageStats) {
+	if stats.BytesIn > 0 {
+		r.record("end %d %s in=%d", stage.Index, stage.Name, stats.BytesIn)
+	} else {
+		r.record("end %d %s out=%d", stage.Index, stage.Name, stats.BytesOut)
+	}
+}
+
+func (r *recordTracer) Error(stage StageInfo, err error) {
+	r.record("error %d %s: %v", stage.Index, stage.Name, err)
+}
@@ -50,6 +50,13 @@ func (u *Uniq) Modify(line []byte) ([]byte, error) {
 	return out, nil
 }
 
+func (u *Uniq) Shell() string {
+	if u.WriteCount {
+		return "uniq -c"
+	}
+	return "uniq"
+}
+
 func (u *Uniq) Name() string {
 	return fmt.Sprintf("uniq(%v)", u.WriteCount)
 }
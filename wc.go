@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
+	"io"
 	"strings"
 )
 
@@ -11,39 +12,53 @@ import (
 type Count struct {
 	// Stream can be used to pipe the output of wc.
 	Stream
-	// Count the number of lines, words and chars in the input.
+	// Counts are set once the stream is read.
+	*Counts
+}
+
+// Counts are the number of lines, words and chars in the input of `wc`.
+type Counts struct {
 	Lines, Words, Chars int
 }
 
-// Wc counts the number of lines, words and characters.
+// Wc counts the number of lines, words and characters. The input is counted on the first read of
+// the stream, and the counts are available after the stream was read.
 //
 // Shell command: `wc`.
 func (s Stream) Wc() Count {
-	defer s.Close()
+	count := Count{Counts: &Counts{}}
+	count.Stream = s.Through(wcPipe{counts: count.Counts})
+	return count
+}
+
+// wcPipe is a pipe that counts its input into counts, and outputs the counts.
+type wcPipe struct {
+	counts *Counts
+}
+
+func (p wcPipe) Name() string { return "wc" }
 
-	var (
-		count Count
-		merr  error
-	)
-	scanner := bufio.NewScanner(s)
+func (p wcPipe) Shell() string { return "wc" }
+
+func (p wcPipe) Pipe(stdin io.Reader) (io.Reader, error) {
+	return &lazyReader{create: func() (io.Reader, error) { return p.count(stdin) }}, nil
+}
+
+func (p wcPipe) count(stdin io.Reader) (io.Reader, error) {
+	var merr error
+	scanner := bufio.NewScanner(stdin)
 	for scanner.Scan() {
-		count.Lines++
-		count.Chars += len(scanner.Text()) + 1
-		count.Words += countWords(scanner.Text())
+		p.counts.Lines++
+		p.counts.Chars += len(scanner.Text()) + 1
+		p.counts.Words += countWords(scanner.Text())
 	}
 	if err := scanner.Err(); err != nil {
 		merr = errors.Join(merr, fmt.Errorf("scanning stream: %w", err))
 	}
-
-	count.Stream = Stream{
-		stage: "wc",
-		r:     strings.NewReader(count.String()),
-		err:   merr,
-	}
-	return count
+	return strings.NewReader(p.counts.String()), merr
 }
 
-func (c Count) String() string {
+func (c Counts) String() string {
 	return fmt.Sprintf("%d\t%d\t%d\n", c.Lines, c.Words, c.Chars)
 }
 
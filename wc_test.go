@@ -2,6 +2,7 @@ package script
 
 import (
 	"fmt"
+	"io"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -14,17 +15,18 @@ func TestWc(t *testing.T) {
 	t.Run("Multiple lines words and chars", func(t *testing.T) {
 		wc := Echo("a b c\nd e \ng ").Wc()
 
-		assert.Equal(t, 3, wc.Lines)
-		assert.Equal(t, 6, wc.Words)
-		assert.Equal(t, 14, wc.Chars)
-
 		out, err := wc.ToString()
 		require.NoError(t, err)
 		assert.Equal(t, "3\t6\t14\n", out)
+
+		assert.Equal(t, 3, wc.Lines)
+		assert.Equal(t, 6, wc.Words)
+		assert.Equal(t, 14, wc.Chars)
 	})
 
 	t.Run("Empty text", func(t *testing.T) {
 		wc := Echo("").Wc()
+		require.NoError(t, wc.Discard())
 
 		assert.Equal(t, 1, wc.Lines)
 		assert.Equal(t, 0, wc.Words)
@@ -39,11 +41,24 @@ func TestWc(t *testing.T) {
 		})
 		wc := s.Wc()
 
+		_, err := wc.ToString()
+		require.ErrorContains(t, err, "oops")
+
 		assert.Equal(t, 0, wc.Lines)
 		assert.Equal(t, 0, wc.Words)
 		assert.Equal(t, 0, wc.Chars)
+	})
 
-		_, err := wc.ToString()
-		require.ErrorContains(t, err, "oops")
+	t.Run("Lazy", func(t *testing.T) {
+		var read bool
+		s := From("reader", readerFn(func(_ []byte) (int, error) {
+			read = true
+			return 0, io.EOF
+		}))
+		wc := s.Wc()
+		assert.False(t, read)
+
+		require.NoError(t, wc.Discard())
+		assert.True(t, read)
 	})
 }